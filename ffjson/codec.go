@@ -0,0 +1,36 @@
+package ffjson
+
+/**
+ *  Copyright 2015 Paul Querna, Klaus Post
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+import (
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
+)
+
+// RegisterCodec registers functions that encode and decode values of
+// type T, for types ffjson cannot generate code for, such as types from
+// other packages.
+//
+// Generated code uses them wherever it would otherwise fall back to
+// encoding/json for a T or *T, and so do Marshal, Unmarshal, the
+// Encoder and the decoders when given a T or *T directly.
+// Either function may be nil to keep using encoding/json in that direction.
+// Codecs should be registered before encoding or decoding starts,
+// typically from an init function.
+func RegisterCodec[T any](enc func(buf fflib.EncodingBuffer, v *T) error, dec func(data []byte, v *T) error) {
+	fflib.RegisterCodec(enc, dec)
+}
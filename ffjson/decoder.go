@@ -18,6 +18,7 @@ package ffjson
  */
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
@@ -26,10 +27,161 @@ import (
 	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
 )
 
+// decodeOptions holds the settings a decoder applies to its lexer
+// and to the encoding/json fallback.
+type decodeOptions struct {
+	disallowUnknownFields bool
+	numberMode            fflib.NumberMode
+	limits                fflib.Limits
+	bufferSize            int
+	syntax                fflib.Syntax
+	utf8Policy            fflib.UTF8Policy
+	duplicateKeys         fflib.DuplicateKeyPolicy
+}
+
+func (o *decodeOptions) apply(fs *fflib.FFLexer) {
+	fs.DisallowUnknownFields = o.disallowUnknownFields
+	fs.NumberMode = o.numberMode
+	fs.Limits = o.limits
+	fs.Syntax = o.syntax
+	fs.UTF8Policy = o.utf8Policy
+	fs.DuplicateKeys = o.duplicateKeys
+}
+
+// strict reports whether input must be exactly one RFC 8259 JSON value.
+func (o *decodeOptions) strict() bool {
+	return o.syntax == fflib.SyntaxStrict
+}
+
+// readAll reads r for decoders that only read JSON, such as
+// encoding/json. In SyntaxStrict, under UTF8Reject and under
+// DuplicateError the input is checked by the lexer first, since
+// encoding/json replaces invalid UTF-8 and lone surrogates, keeps the
+// last of duplicate keys, and json.Decoder ignores trailing data.
+// In SyntaxJSON5 the first value is converted to JSON.
+func (o *decodeOptions) readAll(r io.Reader) ([]byte, error) {
+	if o.syntax == fflib.SyntaxJSON5 {
+		fs := fflib.NewFFLexer(r)
+		defer fs.Release()
+		fs.Syntax = o.syntax
+		fs.UTF8Policy = o.utf8Policy
+		tok := fs.Scan()
+		if tok == fflib.FFTok_error {
+			return nil, fs.ScanError()
+		}
+		b, err := fs.CaptureField(tok)
+		if err != nil {
+			return nil, fs.WrapErr(err)
+		}
+		b = bytes.Clone(b)
+		if o.duplicateKeys == fflib.DuplicateError {
+			err = o.validate(b)
+		}
+		return b, err
+	}
+
+	b, err := io.ReadAll(r)
+	if err == nil && o.validates() {
+		err = o.validate(b)
+	}
+	return b, err
+}
+
+// validates reports whether readAll checks its input.
+func (o *decodeOptions) validates() bool {
+	return o.strict() || o.utf8Policy == fflib.UTF8Reject || o.duplicateKeys == fflib.DuplicateError
+}
+
+// validate is fflib.Validate with the UTF-8 and duplicate key
+// policies of o. Duplicate keys are only found by DecodeInterface.
+func (o *decodeOptions) validate(b []byte) error {
+	fs := fflib.NewFFLexerBytes(b)
+	defer fs.Release()
+	fs.Syntax = o.syntax
+	fs.UTF8Policy = o.utf8Policy
+	fs.DuplicateKeys = o.duplicateKeys
+
+	tok := fs.Scan()
+	if tok == fflib.FFTok_error {
+		return fs.ScanError()
+	}
+	var err error
+	if o.duplicateKeys == fflib.DuplicateError {
+		_, err = fs.DecodeInterface(tok)
+	} else {
+		err = fs.SkipField(tok)
+	}
+	if err != nil {
+		return fs.WrapErr(err)
+	}
+	if o.strict() {
+		return fs.ExpectEOF()
+	}
+	return nil
+}
+
+// decodeJSON decodes r with encoding/json, which always replaces
+// invalid UTF-8.
+func (o *decodeOptions) decodeJSON(r io.Reader, v interface{}) error {
+	if o.syntax == fflib.SyntaxDefault && !o.validates() {
+		return o.newJSONDecoder(r).Decode(v)
+	}
+	b, err := o.readAll(r)
+	if err != nil {
+		return err
+	}
+	return o.newJSONDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// limitInput applies MaxInputSize to input that bypasses the lexer.
+func (o *decodeOptions) limitInput(r io.Reader) io.Reader {
+	if o.limits.MaxInputSize <= 0 {
+		return r
+	}
+	return &limitReader{r: r, max: o.limits.MaxInputSize}
+}
+
+// limitReader reads at most max bytes from r, and fails with a
+// *fflib.LimitError if r holds more.
+type limitReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n >= l.max {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, &fflib.LimitError{Limit: "MaxInputSize", Max: l.max, Offset: l.max}
+		}
+		return 0, err
+	}
+	if rem := l.max - l.n; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+func (o *decodeOptions) newJSONDecoder(r io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(r)
+	if o.disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if o.numberMode == fflib.NumberJSON {
+		decoder.UseNumber()
+	}
+	return decoder
+}
+
 // This is a reusable decoder.
 // This should not be used by more than one goroutine at the time.
 type Decoder struct {
-	fs *fflib.FFLexer
+	fs   *fflib.FFLexer
+	opts decodeOptions
 }
 
 // NewDecoder returns a reusable Decoder.
@@ -37,20 +189,115 @@ func NewDecoder() *Decoder {
 	return &Decoder{}
 }
 
+// DisallowUnknownFields causes the Decoder to return an error when the
+// destination is a struct and the input contains object keys which do
+// not match any non-ignored, exported fields in the destination.
+// For generated decoders the error is a *fflib.UnknownFieldError.
+func (d *Decoder) DisallowUnknownFields() {
+	d.opts.disallowUnknownFields = true
+}
+
+// UseNumber causes the Decoder to unmarshal a number into an interface{}
+// as a json.Number instead of as a float64.
+func (d *Decoder) UseNumber() {
+	d.opts.numberMode = fflib.NumberJSON
+}
+
+// UseInt64 causes generated decoders to unmarshal an integer into an
+// interface{} as an int64 instead of as a float64. Integers that do not
+// fit an int64, and types without generated code, still use float64.
+func (d *Decoder) UseInt64() {
+	d.opts.numberMode = fflib.NumberInt64
+}
+
+// SetLimits bounds the resources spent decoding untrusted input.
+// Exceeding a limit returns an error wrapping a *fflib.LimitError.
+// For types without generated code only MaxInputSize is enforced.
+func (d *Decoder) SetLimits(l fflib.Limits) {
+	d.opts.limits = l
+}
+
+// SetBufferSize sets the initial size of the read buffer. By default
+// it is chosen from the sizes of earlier inputs.
+func (d *Decoder) SetBufferSize(n int) {
+	d.opts.bufferSize = n
+}
+
+// SetSyntax selects the grammar of the input. With fflib.SyntaxStrict
+// only RFC 8259 JSON is accepted, and nothing but whitespace may
+// follow the value. fflib.SyntaxJSON5 accepts JSON5, such as
+// hand-edited configuration files.
+func (d *Decoder) SetSyntax(s fflib.Syntax) {
+	d.opts.syntax = s
+}
+
+// SetUTF8Policy selects what happens to invalid UTF-8 in strings.
+// With fflib.UTF8Reject decoding fails with a *fflib.SyntaxError
+// wrapping a *fflib.UTF8Error, which gives the input offset. Types
+// without generated code are decoded by encoding/json, which always
+// replaces invalid bytes, so UTF8PassThrough only applies to
+// generated code.
+func (d *Decoder) SetUTF8Policy(p fflib.UTF8Policy) {
+	d.opts.utf8Policy = p
+}
+
+// SetDuplicateKeys selects what happens to a key that appears twice
+// in an object. By default the last value wins, like encoding/json.
+// With fflib.DuplicateError decoding fails with an error wrapping a
+// *fflib.DuplicateKeyError. Types without generated code are decoded
+// by encoding/json, which keeps the last value even under
+// fflib.DuplicateFirstWins, but their input is checked for
+// fflib.DuplicateError.
+func (d *Decoder) SetDuplicateKeys(p fflib.DuplicateKeyPolicy) {
+	d.opts.duplicateKeys = p
+}
+
 // Decode the data in the supplied data slice.
 func (d *Decoder) Decode(data io.Reader, v interface{}) error {
+	return d.DecodeSize(data, 0, v)
+}
+
+// DecodeSize is like Decode, with the expected size of the input,
+// such as the Content-Length of an HTTP request. Types with generated
+// code then read the whole input at once, if it is no larger than
+// 4 MB. A size of zero or less is ignored.
+func (d *Decoder) DecodeSize(data io.Reader, size int64, v interface{}) error {
 	f, ok := v.(unmarshalFaster)
 	if ok {
-		if d.fs == nil {
-			d.fs = fflib.NewFFLexer(data)
-		} else {
-			d.fs.Reset(data)
+		d.reset(data, size)
+		return d.decodeFast(f)
+	}
+
+	data = d.opts.limitInput(data)
+	if fflib.HasDecodeCodec(v) {
+		b, err := d.opts.readAll(data)
+		if err != nil {
+			return err
 		}
-		return f.UnmarshalJSONFFLexer(d.fs, fflib.FFParse_map_start)
+		_, err = fflib.DecodeWithCodec(b, v)
+		return err
 	}
 
-	decoder := json.NewDecoder(data)
-	return decoder.Decode(v)
+	return d.opts.decodeJSON(data, v)
+}
+
+func (d *Decoder) decodeFast(f unmarshalFaster) (err error) {
+	defer recoverTooLarge(&err)
+	err = f.UnmarshalJSONFFLexer(d.fs, fflib.FFParse_map_start)
+	if err == nil && d.opts.strict() {
+		err = d.fs.ExpectEOF()
+	}
+	return err
+}
+
+func (d *Decoder) reset(data io.Reader, size int64) {
+	if d.fs == nil {
+		d.fs = fflib.NewFFLexer(data)
+	} else {
+		d.fs.Reset(data)
+	}
+	d.opts.apply(d.fs)
+	d.fs.SetReaderOptions(fflib.ReaderOptions{BufferSize: d.opts.bufferSize, SizeHint: size})
 }
 
 // DecodeFast will unmarshal the data if fast unmarshal is available.
@@ -63,10 +310,330 @@ func (d *Decoder) DecodeFast(data io.Reader, v interface{}) error {
 	if !ok {
 		return errors.New("ffjson unmarshal not available for type " + reflect.TypeOf(v).String())
 	}
-	if d.fs == nil {
-		d.fs = fflib.NewFFLexer(data)
+	d.reset(data, 0)
+	return d.decodeFast(f)
+}
+
+// StreamDecoder decodes consecutive JSON values from a single reader,
+// such as concatenated or newline-delimited JSON.
+// Unlike Decoder, input that has been read ahead is kept between calls.
+// This should not be used by more than one goroutine at the time.
+type StreamDecoder struct {
+	fs   *fflib.FFLexer
+	opts decodeOptions
+	err  error
+}
+
+// NewStreamDecoder returns a StreamDecoder that reads from r.
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	return newStreamDecoder(fflib.NewFFLexer(r))
+}
+
+func newStreamDecoder(fs *fflib.FFLexer) *StreamDecoder {
+	return &StreamDecoder{fs: fs}
+}
+
+// DisallowUnknownFields causes the StreamDecoder to return an error when
+// the destination is a struct and the input contains object keys which
+// do not match any non-ignored, exported fields in the destination.
+// For generated decoders the error is a *fflib.UnknownFieldError.
+func (d *StreamDecoder) DisallowUnknownFields() {
+	d.opts.disallowUnknownFields = true
+}
+
+// UseNumber causes the StreamDecoder to unmarshal a number into an
+// interface{} as a json.Number instead of as a float64.
+func (d *StreamDecoder) UseNumber() {
+	d.opts.numberMode = fflib.NumberJSON
+}
+
+// UseInt64 causes generated decoders to unmarshal an integer into an
+// interface{} as an int64 instead of as a float64. Integers that do not
+// fit an int64, and types without generated code, still use float64.
+func (d *StreamDecoder) UseInt64() {
+	d.opts.numberMode = fflib.NumberInt64
+}
+
+// SetLimits bounds the resources spent decoding untrusted input.
+// MaxInputSize applies to the whole stream, the other limits to each
+// value. Exceeding a limit returns an error wrapping a *fflib.LimitError.
+func (d *StreamDecoder) SetLimits(l fflib.Limits) {
+	d.opts.limits = l
+	d.opts.apply(d.fs)
+}
+
+// SetBufferSize sets the initial size of the read buffer. It must be
+// called before the first Decode. By default the size is chosen from
+// the sizes of earlier inputs.
+func (d *StreamDecoder) SetBufferSize(n int) {
+	d.opts.bufferSize = n
+	d.fs.SetReaderOptions(fflib.ReaderOptions{BufferSize: n})
+}
+
+// SetSyntax selects the grammar of the values in the stream. With
+// fflib.SyntaxStrict each value must be RFC 8259 JSON, and with
+// fflib.SyntaxJSON5 it may use the extensions of JSON5.
+func (d *StreamDecoder) SetSyntax(s fflib.Syntax) {
+	d.opts.syntax = s
+	d.opts.apply(d.fs)
+}
+
+// SetUTF8Policy selects what happens to invalid UTF-8 in strings,
+// as for Decoder.SetUTF8Policy.
+func (d *StreamDecoder) SetUTF8Policy(p fflib.UTF8Policy) {
+	d.opts.utf8Policy = p
+	d.opts.apply(d.fs)
+}
+
+// SetDuplicateKeys selects what happens to a key that appears twice
+// in an object, as for Decoder.SetDuplicateKeys.
+func (d *StreamDecoder) SetDuplicateKeys(p fflib.DuplicateKeyPolicy) {
+	d.opts.duplicateKeys = p
+	d.opts.apply(d.fs)
+}
+
+// Decode the next JSON value from the stream into v.
+// When the stream holds no more values io.EOF is returned.
+// After any other error the stream position is undefined,
+// so the same error is returned by all later calls.
+func (d *StreamDecoder) Decode(v interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+
+	_, err := d.fs.PeekByte()
+	if err != nil {
+		if err != io.EOF {
+			d.err = err
+		}
+		return err
+	}
+
+	d.fs.Error = fflib.FFErr_e_ok
+	d.fs.BigError = nil
+	d.opts.apply(d.fs)
+
+	err = d.decode(v)
+	if err != nil {
+		d.err = err
+	}
+	return err
+}
+
+func (d *StreamDecoder) decode(v interface{}) (err error) {
+	defer recoverTooLarge(&err)
+	if f, ok := v.(unmarshalFaster); ok {
+		return f.UnmarshalJSONFFLexer(d.fs, fflib.FFParse_map_start)
+	}
+	return d.decodeFallback(v)
+}
+
+// recoverTooLarge returns fflib.ErrTooLarge in *err when a buffer
+// panics with it because it cannot grow any further.
+func recoverTooLarge(err *error) {
+	if r := recover(); r != nil {
+		if r != fflib.ErrTooLarge {
+			panic(r)
+		}
+		*err = fflib.ErrTooLarge
+	}
+}
+
+func (d *StreamDecoder) decodeFallback(v interface{}) error {
+	tok := d.fs.Scan()
+	if tok == fflib.FFTok_error {
+		return d.fs.ScanError()
+	}
+
+	buf, err := d.fs.CaptureField(tok)
+	if err != nil {
+		return d.fs.WrapErr(err)
+	}
+
+	ok, err := fflib.DecodeWithCodec(buf, v)
+	if ok {
+		return err
+	}
+	return d.opts.decodeJSON(bytes.NewReader(buf), v)
+}
+
+// More reports whether there is another value in the stream.
+func (d *StreamDecoder) More() bool {
+	if d.err != nil {
+		return false
+	}
+	c, err := d.fs.PeekByte()
+	return err == nil && c != ']' && c != '}'
+}
+
+// Buffered returns a reader of the data remaining in the decoder's
+// buffer. The reader is valid until the next call to Decode.
+func (d *StreamDecoder) Buffered() io.Reader {
+	return bytes.NewReader(d.fs.Buffered())
+}
+
+// InputOffset returns the input stream byte offset of the current
+// decoder position.
+func (d *StreamDecoder) InputOffset() int64 {
+	return d.fs.InputOffset()
+}
+
+// Release hands the decoder's read buffer back to the pool.
+// The StreamDecoder must not be used afterwards.
+func (d *StreamDecoder) Release() {
+	d.fs.Release()
+}
+
+// ArrayIterator decodes the elements of a top-level JSON array one by one,
+// so memory use is proportional to a single element rather than the array.
+// This should not be used by more than one goroutine at the time.
+type ArrayIterator struct {
+	d       *StreamDecoder
+	started bool
+	done    bool
+	index   int
+	err     error
+}
+
+// NewArrayIterator returns an ArrayIterator over the array read from r.
+func NewArrayIterator(r io.Reader) *ArrayIterator {
+	return newArrayIterator(fflib.NewFFLexer(r))
+}
+
+// newArrayIterator returns an ArrayIterator over the array read by fs.
+// Every iterator is set up here, whatever its input.
+func newArrayIterator(fs *fflib.FFLexer) *ArrayIterator {
+	return &ArrayIterator{d: newStreamDecoder(fs)}
+}
+
+// DisallowUnknownFields causes an error to be returned when an element
+// contains object keys which do not match any field of the destination.
+func (it *ArrayIterator) DisallowUnknownFields() {
+	it.d.DisallowUnknownFields()
+}
+
+// UseNumber causes numbers in interface{} values to be decoded as json.Number.
+func (it *ArrayIterator) UseNumber() {
+	it.d.UseNumber()
+}
+
+// UseInt64 causes integers in interface{} values to be decoded as int64
+// by generated decoders.
+func (it *ArrayIterator) UseInt64() {
+	it.d.UseInt64()
+}
+
+// SetLimits bounds the resources spent decoding untrusted input.
+// MaxElements also applies to the array being iterated.
+func (it *ArrayIterator) SetLimits(l fflib.Limits) {
+	it.d.SetLimits(l)
+}
+
+// SetBufferSize sets the initial size of the read buffer.
+// It must be called before the first Next.
+func (it *ArrayIterator) SetBufferSize(n int) {
+	it.d.SetBufferSize(n)
+}
+
+// SetSyntax selects the grammar of the input. With fflib.SyntaxStrict
+// only RFC 8259 JSON is accepted, and nothing but whitespace may
+// follow the array.
+func (it *ArrayIterator) SetSyntax(s fflib.Syntax) {
+	it.d.SetSyntax(s)
+}
+
+// SetUTF8Policy selects what happens to invalid UTF-8 in strings,
+// as for Decoder.SetUTF8Policy.
+func (it *ArrayIterator) SetUTF8Policy(p fflib.UTF8Policy) {
+	it.d.SetUTF8Policy(p)
+}
+
+// SetDuplicateKeys selects what happens to a key that appears twice
+// in an object, as for Decoder.SetDuplicateKeys.
+func (it *ArrayIterator) SetDuplicateKeys(p fflib.DuplicateKeyPolicy) {
+	it.d.SetDuplicateKeys(p)
+}
+
+// Next decodes the next element of the array into v, using the generated
+// decoder if v has one. It returns false at the end of the array or on
+// error; Err tells the two apart. A null element leaves v unchanged.
+func (it *ArrayIterator) Next(v interface{}) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	fs := it.d.fs
+	if !it.started {
+		it.started = true
+		tok := fs.Scan()
+		if tok != fflib.FFTok_left_brace {
+			return it.fail(tok, fflib.FFTok_left_brace)
+		}
+		c, err := fs.PeekByte()
+		if err == nil && c == ']' {
+			fs.Scan()
+			return it.end()
+		}
 	} else {
-		d.fs.Reset(data)
+		tok := fs.Scan()
+		if tok == fflib.FFTok_right_brace {
+			return it.end()
+		}
+		if tok != fflib.FFTok_comma {
+			return it.fail(tok, fflib.FFTok_comma)
+		}
+	}
+
+	fs.PushIndex(it.index)
+	c, err := fs.PeekByte()
+	if err == nil && c == 'n' {
+		tok := fs.Scan()
+		if tok != fflib.FFTok_null {
+			return it.fail(tok, fflib.FFTok_null)
+		}
+	} else {
+		err = it.d.Decode(v)
+		if err == io.EOF {
+			err = fs.UnexpectedToken(fflib.FFTok_init, fflib.FFTok_eof)
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
 	}
-	return f.UnmarshalJSONFFLexer(d.fs, fflib.FFParse_map_start)
+	fs.PopPath()
+	it.index++
+	return true
+}
+
+// end finishes the iteration after the closing ']'.
+func (it *ArrayIterator) end() bool {
+	it.done = true
+	if it.d.opts.strict() {
+		it.err = it.d.fs.ExpectEOF()
+	}
+	return false
+}
+
+func (it *ArrayIterator) fail(tok, wanted fflib.FFTok) bool {
+	it.err = it.d.fs.UnexpectedToken(wanted, tok)
+	return false
+}
+
+// Err returns the error that stopped the iteration, or nil if
+// the end of the array was reached.
+func (it *ArrayIterator) Err() error {
+	return it.err
+}
+
+// InputOffset returns the input stream byte offset of the current
+// iterator position.
+func (it *ArrayIterator) InputOffset() int64 {
+	return it.d.InputOffset()
+}
+
+// Release hands the iterator's read buffer back to the pool.
+// The ArrayIterator must not be used afterwards.
+func (it *ArrayIterator) Release() {
+	it.d.Release()
 }
@@ -30,9 +30,21 @@ import (
 // It allows to encode many objects to a single writer.
 // This should not be used by more than one goroutine at the time.
 type Encoder struct {
-	buf fflib.Buffer
-	w   io.Writer
-	enc *json.Encoder
+	buf    *fflib.StreamBuffer
+	indent *fflib.IndentBuffer
+	w      io.Writer
+	enc    *json.Encoder
+
+	// Open arrays and objects, innermost last.
+	stack []container
+	err   error
+}
+
+// container is an array or object opened with BeginArray or BeginObject.
+type container struct {
+	end byte
+	n   int
+	key bool
 }
 
 // SetEscapeHTML specifies whether problematic HTML characters
@@ -42,31 +54,89 @@ type Encoder struct {
 //
 // In non-HTML settings where the escaping interferes with the readability
 // of the output, SetEscapeHTML(false) disables this behavior.
+// The setting applies to generated marshalers as well as to
+// the encoding/json fallback.
 func (enc *Encoder) SetEscapeHTML(on bool) {
 	enc.enc.SetEscapeHTML(on)
+	enc.buf.SetEscapeHTML(on)
+}
+
+// SetUTF8Policy selects what happens to invalid UTF-8 in strings.
+// With fflib.UTF8Reject, Encode fails with a *fflib.UTF8Error, which
+// gives the offset in the string. Values without generated code are
+// encoded by encoding/json, which always replaces invalid bytes with
+// U+FFFD.
+func (enc *Encoder) SetUTF8Policy(p fflib.UTF8Policy) {
+	enc.buf.SetUTF8Policy(p)
+}
+
+// SetNonFinitePolicy selects how NaN and infinite floats are
+// encoded. The default, fflib.NonFiniteError, fails with a
+// *json.UnsupportedValueError like encoding/json. Values without
+// generated code are encoded by encoding/json, which always fails.
+func (enc *Encoder) SetNonFinitePolicy(p fflib.NonFinitePolicy) {
+	enc.buf.SetNonFinitePolicy(p)
+}
+
+// SetIndent instructs the encoder to format each subsequent encoded
+// value as if indented by json.Indent. Calling SetIndent("", "")
+// disables indentation.
+func (enc *Encoder) SetIndent(prefix, indent string) {
+	enc.enc.SetIndent(prefix, indent)
+	if prefix == "" && indent == "" {
+		enc.indent = nil
+		return
+	}
+	enc.indent = fflib.NewIndentBuffer(enc.buf, prefix, indent)
 }
 
 // NewEncoder returns a reusable Encoder.
 // Output will be written to the supplied writer.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w, enc: json.NewEncoder(w)}
+	return &Encoder{w: w, buf: fflib.NewStreamBuffer(w, 0), enc: json.NewEncoder(w)}
 }
 
 // Encode the data in the supplied value to the stream
 // given on creation.
 // When the function returns the output has been
-// written to the stream.
+// written to the stream, unless an array or object opened with
+// BeginArray or BeginObject is being written; then the value is
+// added as its next element.
+// Generated code writes its output in chunks of fflib.DefaultFlushSize
+// while encoding, so large values do not have to fit in memory.
+// On error the output that is still buffered is discarded, so a value
+// shorter than one chunk writes nothing. A larger value may already
+// have written its first chunks; use Marshal if the output must be
+// all or nothing.
 func (e *Encoder) Encode(v interface{}) error {
+	if len(e.stack) > 0 {
+		return e.encodeElement(v)
+	}
+
 	f, ok := v.(marshalerFaster)
 	if ok {
-		e.buf.Reset()
-		err := f.MarshalJSONBuf(&e.buf)
+		buf := e.out()
+		buf.Reset()
+		err := f.MarshalJSONBuf(buf)
 		if err != nil {
+			buf.Reset()
 			return err
 		}
+		return e.buf.Flush()
+	}
 
-		_, err = io.Copy(e.w, &e.buf)
-		return err
+	buf := e.out()
+	buf.Reset()
+	ok, err := fflib.EncodeWithCodec(buf, v)
+	if ok {
+		if err == nil {
+			err = fflib.UTF8Err(buf)
+		}
+		if err != nil {
+			buf.Reset()
+			return err
+		}
+		return e.buf.Flush()
 	}
 
 	return e.enc.Encode(v)
@@ -84,3 +154,141 @@ func (e *Encoder) EncodeFast(v interface{}) error {
 	}
 	return e.Encode(v)
 }
+
+func (e *Encoder) out() fflib.EncodingBuffer {
+	if e.indent != nil {
+		return e.indent
+	}
+	return e.buf
+}
+
+// BeginArray starts a JSON array. Values passed to Encode are written
+// as its elements, separated by commas, until End is called.
+// Output is written to the stream in chunks, so memory use does
+// not depend on the number of elements. Write errors are reported
+// by End or Flush.
+func (e *Encoder) BeginArray() error {
+	return e.begin('[', ']')
+}
+
+// BeginObject starts a JSON object. Each member is written by
+// calling Key followed by Encode, until End is called.
+func (e *Encoder) BeginObject() error {
+	return e.begin('{', '}')
+}
+
+func (e *Encoder) begin(start, end byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		e.out().Reset()
+	} else if err := e.separate(); err != nil {
+		return err
+	}
+	e.out().WriteByte(start)
+	e.stack = append(e.stack, container{end: end})
+	return nil
+}
+
+// separate writes the comma before a new element of the innermost
+// container, and checks that an object member has a key.
+func (e *Encoder) separate() error {
+	c := &e.stack[len(e.stack)-1]
+	if c.end == '}' {
+		if !c.key {
+			return e.fail(errors.New("ffjson: Key must be called before writing an object member"))
+		}
+		c.key = false
+		return nil
+	}
+	if c.n > 0 {
+		e.out().WriteByte(',')
+	}
+	c.n++
+	return nil
+}
+
+// Key writes the key of the next member of the object opened by BeginObject.
+func (e *Encoder) Key(key string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 || e.stack[len(e.stack)-1].end != '}' || e.stack[len(e.stack)-1].key {
+		return e.fail(errors.New("ffjson: Key called outside of an object"))
+	}
+	c := &e.stack[len(e.stack)-1]
+	buf := e.out()
+	if c.n > 0 {
+		buf.WriteByte(',')
+	}
+	c.n++
+	c.key = true
+	fflib.WriteJsonString(buf, key)
+	buf.WriteByte(':')
+	if err := fflib.UTF8Err(buf); err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+// End closes the innermost array or object. When the outermost one is
+// closed all remaining output is written to the stream.
+func (e *Encoder) End() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		return e.fail(errors.New("ffjson: End called without BeginArray or BeginObject"))
+	}
+	c := e.stack[len(e.stack)-1]
+	if c.key {
+		return e.fail(errors.New("ffjson: End called after Key without a value"))
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	e.out().WriteByte(c.end)
+	if len(e.stack) == 0 {
+		return e.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered output of an open array or object to the stream.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	err := e.buf.Flush()
+	if err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+func (e *Encoder) encodeElement(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	err := e.separate()
+	if err != nil {
+		return err
+	}
+
+	buf := e.out()
+	if f, ok := v.(marshalerFaster); ok {
+		err = f.MarshalJSONBuf(buf)
+	} else {
+		err = fflib.EncodeFallback(buf, v)
+	}
+	if err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+// fail records an error from an open array or object. The output is
+// incomplete at that point, so the same error is returned by all later calls.
+func (e *Encoder) fail(err error) error {
+	e.err = err
+	return err
+}
@@ -0,0 +1,141 @@
+package ffjson
+
+/**
+ *  Copyright 2015 Paul Querna, Klaus Post
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+import (
+	"sort"
+
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
+)
+
+// MarshalerPtr is satisfied by *T when ffjson has generated
+// the marshal code for T.
+type MarshalerPtr[T any] interface {
+	*T
+	MarshalJSONBuf(buf fflib.EncodingBuffer) error
+}
+
+// UnmarshalerPtr is satisfied by *T when ffjson has generated
+// the unmarshal code for T.
+type UnmarshalerPtr[T any] interface {
+	*T
+	UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error
+}
+
+// MarshalSlice encodes s as a JSON array, calling the generated
+// MarshalJSONBuf of each element. A nil slice encodes as null.
+// Unlike Marshal, it only compiles for types with generated code,
+// so the fast path is always used.
+func MarshalSlice[T any, PT MarshalerPtr[T]](s []T) ([]byte, error) {
+	buf := fflib.Buffer{}
+	if s == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+
+	buf.WriteByte('[')
+	for i := range s {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		err := PT(&s[i]).MarshalJSONBuf(&buf)
+		if err != nil {
+			Pool(buf.Bytes())
+			return nil, err
+		}
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// MarshalMap encodes m as a JSON object with sorted keys, like
+// encoding/json, calling the generated MarshalJSONBuf of each value.
+// Nil maps and nil values encode as null.
+func MarshalMap[T any, PT MarshalerPtr[T]](m map[string]PT) ([]byte, error) {
+	buf := fflib.Buffer{}
+	if m == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fflib.WriteJsonString(&buf, key)
+		buf.WriteByte(':')
+		v := m[key]
+		if v == nil {
+			buf.WriteString("null")
+			continue
+		}
+		err := v.MarshalJSONBuf(&buf)
+		if err != nil {
+			Pool(buf.Bytes())
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSlice decodes a JSON array from data, calling the generated
+// UnmarshalJSONFFLexer of each element. null decodes as a nil slice,
+// and null elements as the zero value of T.
+// data must not be modified until the function returns.
+func UnmarshalSlice[T any, PT UnmarshalerPtr[T]](data []byte) ([]T, error) {
+	it := newArrayIterator(fflib.NewFFLexerBytes(data))
+	defer it.Release()
+
+	c, err := it.d.fs.PeekByte()
+	if err == nil && c == 'n' {
+		tok := it.d.fs.Scan()
+		if tok != fflib.FFTok_null {
+			it.fail(tok, fflib.FFTok_null)
+			return nil, it.err
+		}
+		// Like Unmarshal, only whitespace may follow the value.
+		if err := it.d.fs.ExpectEOF(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	s := make([]T, 0)
+	for {
+		var zero T
+		s = append(s, zero)
+		if !it.Next(PT(&s[len(s)-1])) {
+			break
+		}
+	}
+	if it.err != nil {
+		return nil, it.err
+	}
+	if err := it.d.fs.ExpectEOF(); err != nil {
+		return nil, err
+	}
+	return s[:len(s)-1], nil
+}
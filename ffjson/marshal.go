@@ -22,6 +22,7 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"sync"
 
 	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
 )
@@ -34,6 +35,10 @@ type unmarshalFaster interface {
 	UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error
 }
 
+type unmarshalReader interface {
+	UnmarshalJSONReader(r io.Reader) error
+}
+
 // Marshal will act the same way as json.Marshal, except
 // it will choose the ffjson marshal function before falling
 // back to using json.Marshal.
@@ -56,6 +61,16 @@ func Marshal(v interface{}) ([]byte, error) {
 		return b, nil
 	}
 
+	buf := fflib.Buffer{}
+	ok, err := fflib.EncodeWithCodec(&buf, v)
+	if ok {
+		if err != nil {
+			Pool(buf.Bytes())
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
 	j, ok := v.(json.Marshaler)
 	if ok {
 		return j.MarshalJSON()
@@ -63,6 +78,82 @@ func Marshal(v interface{}) ([]byte, error) {
 	return json.Marshal(v)
 }
 
+var appendBuffers = sync.Pool{
+	New: func() interface{} { return new(fflib.Buffer) },
+}
+
+// MarshalAppend appends the JSON encoding of v to dst and returns the
+// extended slice, like Marshal otherwise.
+// The output is written directly into dst while it has capacity,
+// so with generated code and a large enough dst no allocation is made.
+// dst is never handed to the pool; the result is owned by the caller.
+// On error dst is returned unchanged.
+func MarshalAppend(dst []byte, v interface{}) ([]byte, error) {
+	buf := appendBuffers.Get().(*fflib.Buffer)
+	buf.Wrap(dst)
+
+	var err error
+	if f, ok := v.(marshalerFaster); ok {
+		err = f.MarshalJSONBuf(buf)
+	} else {
+		err = fflib.EncodeFallback(buf, v)
+	}
+
+	out := buf.Bytes()
+	buf.Wrap(nil)
+	appendBuffers.Put(buf)
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
+
+// MarshalTo writes the JSON encoding of v to w.
+// Types with ffjson code are written in chunks while they are encoded,
+// so memory use stays bounded however large the output is.
+// On error nothing more is written, so output shorter than
+// fflib.DefaultFlushSize never reaches w. Larger output may already
+// have been partly written; use Marshal if it must be all or nothing.
+func MarshalTo(w io.Writer, v interface{}) error {
+	buf := fflib.NewStreamBuffer(w, 0)
+	var err error
+	if f, ok := v.(marshalerFaster); ok {
+		err = f.MarshalJSONBuf(buf)
+	} else {
+		err = fflib.EncodeFallback(buf, v)
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	Pool(buf.Bytes())
+	return err
+}
+
+// MarshalIndent is like Marshal but applies indentation to format
+// the output, the same way json.MarshalIndent does.
+// Types with ffjson code are indented while they are encoded,
+// without parsing the output again.
+func MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	buf := fflib.Buffer{}
+	ib := fflib.NewIndentBuffer(&buf, prefix, indent)
+
+	var err error
+	if f, ok := v.(marshalerFaster); ok {
+		err = f.MarshalJSONBuf(ib)
+	} else if ok, err = fflib.EncodeWithCodec(ib, v); !ok {
+		return json.MarshalIndent(v, prefix, indent)
+	}
+
+	b := buf.Bytes()
+	if err != nil {
+		if len(b) > 0 {
+			Pool(b)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
 // MarshalFast will marshal the data if fast marshal is available.
 // This function can be used if you want to be sure the fast
 // marshal is used or in testing.
@@ -83,15 +174,30 @@ func MarshalFast(v interface{}) ([]byte, error) {
 // however this should still provide a speedup for your encoding.
 // It is ok to call this function even if no ffjson code has been
 // generated for the data type you pass in the interface.
-func Unmarshal(data io.Reader, v interface{}) error {
+func Unmarshal(data io.Reader, v interface{}) (err error) {
 	f, ok := v.(unmarshalFaster)
 	if ok {
 		fs := fflib.NewFFLexer(data)
 		defer fs.Release()
+		defer recoverTooLarge(&err)
 
 		return f.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 	}
 
+	r, ok := v.(unmarshalReader)
+	if ok {
+		return r.UnmarshalJSONReader(data)
+	}
+
+	if fflib.HasDecodeCodec(v) {
+		b, err := io.ReadAll(data)
+		if err != nil {
+			return err
+		}
+		_, err = fflib.DecodeWithCodec(b, v)
+		return err
+	}
+
 	decoder := json.NewDecoder(data)
 	return decoder.Decode(v)
 }
@@ -108,3 +214,40 @@ func UnmarshalFast(data io.Reader, v interface{}) error {
 	}
 	return Unmarshal(data, v)
 }
+
+// UnmarshalBytes will act the same way as json.Unmarshal, except
+// it will choose the ffjson unmarshal function before falling
+// back to using json.Unmarshal.
+// Unlike Unmarshal the lexer works directly on data, so no
+// read buffer is used and the input is never copied.
+// data must not be modified until the function returns.
+func UnmarshalBytes(data []byte, v interface{}) (err error) {
+	f, ok := v.(unmarshalFaster)
+	if ok {
+		fs := fflib.NewFFLexerBytes(data)
+		defer fs.Release()
+		defer recoverTooLarge(&err)
+
+		err = f.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+		if err != nil {
+			return err
+		}
+		// Like json.Unmarshal, only whitespace may follow the value.
+		return fs.ExpectEOF()
+	}
+
+	return fflib.DecodeFallback(data, v)
+}
+
+// UnmarshalBytesFast will unmarshal the data if fast unmarshal is available.
+// This function can be used if you want to be sure the fast
+// unmarshal is used or in testing.
+// If you would like to have fallback to encoding/json you can use the
+// UnmarshalBytes() method.
+func UnmarshalBytesFast(data []byte, v interface{}) error {
+	_, ok := v.(unmarshalFaster)
+	if !ok {
+		return errors.New("ffjson unmarshal not available for type " + reflect.TypeOf(v).String())
+	}
+	return UnmarshalBytes(data, v)
+}
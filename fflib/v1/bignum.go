@@ -0,0 +1,214 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// ValidNumber reports whether b is a valid JSON number, as checked by
+// encoding/json before it stores a string in a json.Number.
+func ValidNumber(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	if b[0] == '-' {
+		b = b[1:]
+		if len(b) == 0 {
+			return false
+		}
+	}
+
+	switch {
+	case b[0] == '0':
+		b = b[1:]
+	case '1' <= b[0] && b[0] <= '9':
+		b = b[1:]
+		for len(b) > 0 && '0' <= b[0] && b[0] <= '9' {
+			b = b[1:]
+		}
+	default:
+		return false
+	}
+
+	if len(b) >= 2 && b[0] == '.' && '0' <= b[1] && b[1] <= '9' {
+		b = b[2:]
+		for len(b) > 0 && '0' <= b[0] && b[0] <= '9' {
+			b = b[1:]
+		}
+	}
+
+	if len(b) >= 2 && (b[0] == 'e' || b[0] == 'E') {
+		b = b[1:]
+		if b[0] == '+' || b[0] == '-' {
+			b = b[1:]
+			if len(b) == 0 {
+				return false
+			}
+		}
+		for len(b) > 0 && '0' <= b[0] && b[0] <= '9' {
+			b = b[1:]
+		}
+	}
+
+	return len(b) == 0
+}
+
+// WriteJsonNumber writes n as encoding/json does: an empty n is
+// written as 0, and an invalid one is an error. If quoted, n is
+// written as a JSON string, as for the ",string" option.
+func WriteJsonNumber(buf EncodingBuffer, n json.Number, quoted bool) error {
+	s := string(n)
+	if s == "" {
+		s = "0"
+	}
+	if !ValidNumber([]byte(s)) {
+		return fmt.Errorf("json: invalid number literal %q", s)
+	}
+	if quoted {
+		buf.WriteByte('"')
+	}
+	buf.WriteString(s)
+	if quoted {
+		buf.WriteByte('"')
+	}
+	return nil
+}
+
+func bigSyntaxError(fn string, b []byte) error {
+	return &strconv.NumError{Func: fn, Num: string(b), Err: strconv.ErrSyntax}
+}
+
+// ParseBigInt parses the integer in b.
+func ParseBigInt(b []byte) (*big.Int, error) {
+	z, ok := new(big.Int).SetString(string(b), 10)
+	if !ok {
+		return nil, bigSyntaxError("ParseBigInt", b)
+	}
+	return z, nil
+}
+
+// ParseBigFloat parses the number in b, with enough precision to
+// hold all of its digits, and at least the 64 bits encoding/json
+// uses.
+func ParseBigFloat(b []byte) (*big.Float, error) {
+	// log2(10) < 3.33 bits per digit.
+	prec := uint(len(b))*333/100 + 1
+	if prec < 64 {
+		prec = 64
+	}
+	z, _, err := new(big.Float).SetPrec(prec).Parse(string(b), 10)
+	if err != nil {
+		return nil, &strconv.NumError{Func: "ParseBigFloat", Num: string(b), Err: err}
+	}
+	return z, nil
+}
+
+// ParseBigRat parses the number in b exactly. Like big.Rat's
+// UnmarshalText it also accepts fractions such as 1/3.
+func ParseBigRat(b []byte) (*big.Rat, error) {
+	z, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		return nil, bigSyntaxError("ParseBigRat", b)
+	}
+	return z, nil
+}
+
+// WriteBigInt writes x as a JSON number, or null if x is nil.
+func WriteBigInt(buf EncodingBuffer, x *big.Int) error {
+	if x == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	var b [64]byte
+	buf.Write(x.Append(b[:0], 10))
+	return nil
+}
+
+// WriteBigFloat writes x as encoding/json does, as a JSON string of
+// x.Text('g', -1), or null if x is nil. Unless quoted, x is written as
+// a JSON number instead, and infinities are an error.
+func WriteBigFloat(buf EncodingBuffer, x *big.Float, quoted bool) error {
+	if x == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	if x.IsInf() && !quoted {
+		return fmt.Errorf("ffjson: unsupported value: %v", x)
+	}
+	var b [64]byte
+	if quoted {
+		buf.WriteByte('"')
+	}
+	buf.Write(x.Append(b[:0], 'g', -1))
+	if quoted {
+		buf.WriteByte('"')
+	}
+	return nil
+}
+
+// WriteBigRat writes x as encoding/json does, as a JSON string of its
+// MarshalText, such as "1/3", or null if x is nil. Unless quoted, x is
+// written as an exact JSON number instead, and a fraction without a
+// finite decimal expansion, such as 1/3, is an error rather than
+// being rounded.
+func WriteBigRat(buf EncodingBuffer, x *big.Rat, quoted bool) error {
+	if x == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	if quoted {
+		b, _ := x.MarshalText()
+		buf.WriteByte('"')
+		buf.Write(b)
+		buf.WriteByte('"')
+		return nil
+	}
+	if x.IsInt() {
+		return WriteBigInt(buf, x.Num())
+	}
+
+	// The expansion is finite if the denominator has no prime
+	// factors but 2 and 5, and then needs as many digits as the
+	// larger of their powers.
+	d := new(big.Int).Set(x.Denom())
+	twos := d.TrailingZeroBits()
+	d.Rsh(d, twos)
+	fives := uint(0)
+	five := big.NewInt(5)
+	var q, r big.Int
+	for {
+		q.QuoRem(d, five, &r)
+		if r.Sign() != 0 {
+			break
+		}
+		d.Set(&q)
+		fives++
+	}
+	if !d.IsInt64() || d.Int64() != 1 {
+		return fmt.Errorf("ffjson: %v has no exact decimal representation", x)
+	}
+	if fives > twos {
+		twos = fives
+	}
+	buf.WriteString(x.FloatString(int(twos)))
+	return nil
+}
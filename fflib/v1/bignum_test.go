@@ -0,0 +1,123 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestValidNumber(t *testing.T) {
+	for _, s := range []string{"0", "-0", "1", "-12.5", "1e3", "1E+3", "0.5e-10"} {
+		if !ValidNumber([]byte(s)) {
+			t.Errorf("%s: expected valid", s)
+		}
+	}
+	for _, s := range []string{"", "-", "01", "1.", ".5", "+1", "1e", "1e+", "0x10", "NaN", " 1"} {
+		if ValidNumber([]byte(s)) {
+			t.Errorf("%q: expected invalid", s)
+		}
+	}
+}
+
+func TestWriteJsonNumber(t *testing.T) {
+	var buf Buffer
+	for _, tc := range []struct {
+		n      string
+		quoted bool
+		out    string
+	}{
+		{"", false, "0"},
+		{"1.5e300", false, "1.5e300"},
+		{"-2", true, `"-2"`},
+	} {
+		buf.Reset()
+		if err := WriteJsonNumber(&buf, json.Number(tc.n), tc.quoted); err != nil || buf.String() != tc.out {
+			t.Errorf("%q: expected %s, got %s %v", tc.n, tc.out, buf.String(), err)
+		}
+	}
+	if err := WriteJsonNumber(&buf, "1.", false); err == nil {
+		t.Error("invalid number accepted")
+	}
+}
+
+func TestWriteBigRat(t *testing.T) {
+	var buf Buffer
+	for _, tc := range []struct {
+		r   *big.Rat
+		out string
+	}{
+		{big.NewRat(5, 1), "5"},
+		{big.NewRat(-1, 8), "-0.125"},
+		{big.NewRat(7, 20), "0.35"},
+		{big.NewRat(1, 3125), "0.00032"},
+		{nil, "null"},
+	} {
+		buf.Reset()
+		if err := WriteBigRat(&buf, tc.r, false); err != nil || buf.String() != tc.out {
+			t.Errorf("%v: expected %s, got %s %v", tc.r, tc.out, buf.String(), err)
+		}
+	}
+	for _, r := range []*big.Rat{big.NewRat(1, 3), big.NewRat(1, 6)} {
+		if err := WriteBigRat(&buf, r, false); err == nil {
+			t.Errorf("%v: expected error", r)
+		}
+	}
+
+	// Quoted, the output is that of encoding/json.
+	for _, r := range []*big.Rat{big.NewRat(5, 1), big.NewRat(-1, 8), big.NewRat(1, 3), nil} {
+		buf.Reset()
+		expected, _ := json.Marshal(r)
+		if err := WriteBigRat(&buf, r, true); err != nil || buf.String() != string(expected) {
+			t.Errorf("%v: expected %s, got %s %v", r, expected, buf.String(), err)
+		}
+	}
+}
+
+func TestWriteBigFloat(t *testing.T) {
+	var buf Buffer
+	pi, _ := new(big.Float).SetPrec(200).SetString("3.1415926535897932384626433832795028841971")
+	for _, f := range []*big.Float{pi, big.NewFloat(-1e300), big.NewFloat(math.Inf(1)), nil} {
+		buf.Reset()
+		expected, _ := json.Marshal(f)
+		if err := WriteBigFloat(&buf, f, true); err != nil || buf.String() != string(expected) {
+			t.Errorf("%v: expected %s, got %s %v", f, expected, buf.String(), err)
+		}
+	}
+
+	buf.Reset()
+	if err := WriteBigFloat(&buf, pi, false); err != nil || buf.String() != pi.Text('g', -1) {
+		t.Errorf("expected %s, got %s %v", pi.Text('g', -1), buf.String(), err)
+	}
+	if err := WriteBigFloat(&buf, big.NewFloat(math.Inf(-1)), false); err == nil {
+		t.Error("expected error for -Inf")
+	}
+}
+
+func TestParseBigFloat(t *testing.T) {
+	const pi = "3.1415926535897932384626433832795028841971"
+	f, err := ParseBigFloat([]byte(pi))
+	if err != nil || f.Text('g', -1) != pi {
+		t.Errorf("expected %s, got %v %v", pi, f, err)
+	}
+	if _, err := ParseBigFloat([]byte("1.5x")); err == nil {
+		t.Error("invalid number accepted")
+	}
+}
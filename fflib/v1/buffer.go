@@ -80,11 +80,28 @@ type Buffer struct {
 	runeBytes        [utf8.UTFMax]byte // avoid allocation of slice on each WriteByte or Rune
 	encoder          *json.Encoder
 	skipTrailingByte bool
+	noEscapeHTML     bool
+	borrowed         bool // buf belongs to the caller and must not be pooled
+	utf8Policy       UTF8Policy
+	utf8Err          *UTF8Error
+	nonFinite        NonFinitePolicy
 }
 
 // ErrTooLarge is passed to panic if memory cannot be allocated to store data in a buffer.
+// The decoders in package ffjson recover it and return it as an error.
 var ErrTooLarge = errors.New("fflib.v1.Buffer: too large")
 
+// allocSlice allocates a slice of n bytes, panicking with ErrTooLarge
+// instead of a runtime error if n is too large or has overflowed.
+func allocSlice(n int) []byte {
+	defer func() {
+		if recover() != nil {
+			panic(ErrTooLarge)
+		}
+	}()
+	return make([]byte, n)
+}
+
 // Bytes returns a slice of the contents of the unread portion of the buffer;
 // len(b.Bytes()) == b.Len().  If the caller changes the contents of the
 // returned slice, the contents of the buffer will change provided there
@@ -116,9 +133,11 @@ func (b *Buffer) Truncate(n int) {
 	}
 }
 
-// Reset resets the buffer so it has no content.
-// b.Reset() is the same as b.Truncate(0).
-func (b *Buffer) Reset() { b.Truncate(0) }
+// Reset resets the buffer so it has no content, and clears UTF8Err.
+func (b *Buffer) Reset() {
+	b.Truncate(0)
+	b.utf8Err = nil
+}
 
 // grow grows the buffer to guarantee space for n more bytes.
 // It returns the index where bytes should be written.
@@ -148,7 +167,10 @@ func (b *Buffer) grow(n int) int {
 			// not enough space anywhere
 			buf = makeSlice(2*cap(b.buf) + n)
 			copy(buf, b.buf[b.off:])
-			Pool(b.buf)
+			if !b.borrowed {
+				Pool(b.buf)
+			}
+			b.borrowed = false
 			b.buf = buf
 		}
 		b.off = 0
@@ -273,10 +295,22 @@ func (b *Buffer) Rewind(n int) error {
 	return nil
 }
 
+// SetEscapeHTML specifies whether <, > and & are escaped in JSON strings
+// written to the buffer, by WriteJson and by Encode. The default is true.
+func (b *Buffer) SetEscapeHTML(on bool) {
+	b.noEscapeHTML = !on
+}
+
+// EscapeHTML reports whether <, > and & are escaped in JSON strings.
+func (b *Buffer) EscapeHTML() bool {
+	return !b.noEscapeHTML
+}
+
 func (b *Buffer) Encode(v interface{}) error {
 	if b.encoder == nil {
 		b.encoder = json.NewEncoder(b)
 	}
+	b.encoder.SetEscapeHTML(!b.noEscapeHTML)
 	b.skipTrailingByte = true
 	err := b.encoder.Encode(v)
 	b.skipTrailingByte = false
@@ -408,7 +442,18 @@ func (b *Buffer) ReadString(delim byte) (line string, err error) {
 //
 // In most cases, new(Buffer) (or just declaring a Buffer variable) is
 // sufficient to initialize a Buffer.
-func NewBuffer(buf []byte) *Buffer { return &Buffer{buf: buf} }
+// The buffer never hands buf to the pool.
+func NewBuffer(buf []byte) *Buffer { return &Buffer{buf: buf, borrowed: true} }
+
+// Wrap discards the content of the buffer and makes further writes append
+// to dst, so data can be encoded into memory owned by the caller.
+// While dst has room no allocation is made; Bytes returns dst with the
+// written data appended. dst is never handed to the pool.
+func (b *Buffer) Wrap(dst []byte) {
+	b.buf = dst
+	b.off = 0
+	b.borrowed = true
+}
 
 // NewBufferString creates and initializes a new Buffer using string s as its
 // initial contents. It is intended to prepare a buffer to read an existing
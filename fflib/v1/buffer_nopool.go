@@ -7,5 +7,5 @@ package v1
 func Pool(b []byte) {}
 
 func makeSlice(n int) []byte {
-	return make([]byte, n)
+	return allocSlice(n)
 }
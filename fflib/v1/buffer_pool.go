@@ -91,6 +91,10 @@ func Pool(b []byte) {
 // makeSlice allocates a slice of size n -- it will attempt to use a pool'ed
 // instance whenever possible.
 func makeSlice(n int) []byte {
+	if n < 0 {
+		// The size has overflowed.
+		panic(ErrTooLarge)
+	}
 	if n <= 64 {
 		return pool64.Get().([]byte)[0:n]
 	}
@@ -100,6 +104,6 @@ func makeSlice(n int) []byte {
 	if pn != -1 {
 		return pools[pn].Get().([]byte)[0:n]
 	} else {
-		return make([]byte, n)
+		return allocSlice(n)
 	}
 }
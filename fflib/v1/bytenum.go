@@ -32,7 +32,7 @@ func ParseFloat(s []byte, bitSize int) (f float64, err error) {
 
 // ParseUint is like ParseInt but for unsigned numbers, and oeprating on []byte
 func ParseUint(s []byte, base int, bitSize int) (n uint64, err error) {
-	if len(s) == 1 {
+	if len(s) == 1 && base >= 10 {
 		switch s[0] {
 		case '0':
 			return 0, nil
@@ -60,7 +60,7 @@ func ParseUint(s []byte, base int, bitSize int) (n uint64, err error) {
 }
 
 func ParseInt(s []byte, base int, bitSize int) (i int64, err error) {
-	if len(s) == 1 {
+	if len(s) == 1 && base >= 10 {
 		switch s[0] {
 		case '0':
 			return 0, nil
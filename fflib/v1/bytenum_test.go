@@ -0,0 +1,56 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBases(t *testing.T) {
+	for _, base := range []int{2, 8, 10, 16, 36} {
+		for _, i := range []int64{0, 1, 2, 9, 10, 11, 15, 16, 255, -1, -10, -255, 1 << 40} {
+			var buf Buffer
+			FormatBits2(&buf, uint64(i), base, i < 0)
+			want := strconv.FormatInt(i, base)
+			if buf.String() != want {
+				t.Errorf("FormatBits2(%d, %d): expected %s, got %s", i, base, want, buf.String())
+			}
+
+			got, err := ParseInt(buf.Bytes(), base, 64)
+			if err != nil || got != i {
+				t.Errorf("ParseInt(%s, %d): expected %d, got %d %v", buf.String(), base, i, got, err)
+			}
+			if i >= 0 {
+				u, err := ParseUint(buf.Bytes(), base, 64)
+				if err != nil || u != uint64(i) {
+					t.Errorf("ParseUint(%s, %d): expected %d, got %d %v", buf.String(), base, i, u, err)
+				}
+			}
+		}
+	}
+
+	for _, s := range []string{"2", "9"} {
+		if _, err := ParseInt([]byte(s), 2, 64); err == nil {
+			t.Errorf("ParseInt(%s, 2): expected error", s)
+		}
+		if _, err := ParseUint([]byte(s), 2, 64); err == nil {
+			t.Errorf("ParseUint(%s, 2): expected error", s)
+		}
+	}
+}
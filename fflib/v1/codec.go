@@ -0,0 +1,142 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// codec holds the functions registered for a type T, wrapped so they
+// accept T, *T or **T.
+type codec struct {
+	encode func(buf EncodingBuffer, v interface{}) (bool, error)
+	decode func(data []byte, v interface{}) (bool, error)
+}
+
+// codecs maps both T and *T to the codec registered for T.
+var codecs sync.Map
+
+// RegisterCodec registers functions that encode and decode values of
+// type T. Generated code and the ffjson package use them instead of
+// encoding/json for T and *T. Either function may be nil, in which case
+// encoding/json is still used in that direction.
+// enc is never called with a nil pointer; nil encodes as null.
+// dec receives the JSON value, which may be null.
+func RegisterCodec[T any](enc func(buf EncodingBuffer, v *T) error, dec func(data []byte, v *T) error) {
+	c := &codec{}
+	if enc != nil {
+		c.encode = func(buf EncodingBuffer, v interface{}) (bool, error) {
+			switch v := v.(type) {
+			case T:
+				return true, enc(buf, &v)
+			case *T:
+				if v == nil {
+					buf.WriteString("null")
+					return true, nil
+				}
+				return true, enc(buf, v)
+			}
+			return false, nil
+		}
+	}
+	if dec != nil {
+		c.decode = func(data []byte, v interface{}) (bool, error) {
+			switch v := v.(type) {
+			case *T:
+				return true, dec(data, v)
+			case **T:
+				if string(data) == "null" {
+					*v = nil
+					return true, nil
+				}
+				if *v == nil {
+					*v = new(T)
+				}
+				return true, dec(data, *v)
+			}
+			return false, nil
+		}
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	codecs.Store(t, c)
+	codecs.Store(reflect.PtrTo(t), c)
+}
+
+func lookupCodec(t reflect.Type) *codec {
+	if t == nil {
+		return nil
+	}
+	c, ok := codecs.Load(t)
+	if !ok {
+		return nil
+	}
+	return c.(*codec)
+}
+
+// EncodeWithCodec encodes v with the codec registered for its type.
+// It reports false if there is none.
+func EncodeWithCodec(buf EncodingBuffer, v interface{}) (bool, error) {
+	c := lookupCodec(reflect.TypeOf(v))
+	if c == nil || c.encode == nil {
+		return false, nil
+	}
+	return c.encode(buf, v)
+}
+
+// DecodeWithCodec decodes data into v, which must be a pointer, with the
+// codec registered for the type v points to. It reports false if there is none.
+func DecodeWithCodec(data []byte, v interface{}) (bool, error) {
+	if !HasDecodeCodec(v) {
+		return false, nil
+	}
+	c := lookupCodec(reflect.TypeOf(v).Elem())
+	return c.decode(data, v)
+}
+
+// EncodeFallback is used by generated code for values it cannot encode
+// itself. It uses a registered codec if there is one, and buf.Encode otherwise.
+func EncodeFallback(buf EncodingBuffer, v interface{}) error {
+	ok, err := EncodeWithCodec(buf, v)
+	if ok {
+		return err
+	}
+	return buf.Encode(v)
+}
+
+// DecodeFallback is used by generated code for values it cannot decode
+// itself. It uses a registered codec if there is one, and json.Unmarshal otherwise.
+func DecodeFallback(data []byte, v interface{}) error {
+	ok, err := DecodeWithCodec(data, v)
+	if ok {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// HasDecodeCodec reports whether DecodeWithCodec would decode into v.
+func HasDecodeCodec(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false
+	}
+	c := lookupCodec(t.Elem())
+	return c != nil && c.decode != nil
+}
@@ -0,0 +1,68 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import "fmt"
+
+// DuplicateKeyPolicy selects what decoders do with an object key
+// that appears more than once in the same object.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateLastWins decodes every value, so the last one wins,
+	// like encoding/json.
+	DuplicateLastWins DuplicateKeyPolicy = iota
+	// DuplicateFirstWins keeps the first value and skips the others.
+	DuplicateFirstWins
+	// DuplicateError fails with a *DuplicateKeyError.
+	DuplicateError
+)
+
+// DuplicateKeyError is returned under DuplicateError for a key that
+// appears twice in an object.
+type DuplicateKeyError struct {
+	// Key is the object key. For struct fields it is the name of the
+	// field in JSON, which may differ in case from the input.
+	Key string
+	// Offset is the input offset of the repeated key.
+	Offset int64
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("ffjson: duplicate key %q at offset %d", e.Key, e.Offset)
+}
+
+// DuplicateKey handles a key seen before in the same object, where
+// keyOffset is the offset of the key and tok is the first token of its
+// new value. Under DuplicateFirstWins the value is skipped, and under
+// DuplicateError a *DuplicateKeyError is returned. Generated decoders
+// call it unless DuplicateKeys is DuplicateLastWins, and then continue
+// after the value.
+func (ffl *FFLexer) DuplicateKey(key string, keyOffset int64, tok FFTok) error {
+	if tok == FFTok_error {
+		return ffl.ScanError()
+	}
+	if ffl.DuplicateKeys == DuplicateError {
+		return ffl.WrapErr(&DuplicateKeyError{Key: key, Offset: keyOffset})
+	}
+	err := ffl.SkipField(tok)
+	if err != nil {
+		return ffl.WrapErr(err)
+	}
+	return nil
+}
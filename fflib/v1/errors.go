@@ -0,0 +1,215 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SyntaxError is returned for input that is not valid JSON.
+type SyntaxError struct {
+	// Offset is the number of input bytes read when the error was found.
+	Offset int64
+	// Line and Column give the same position, both starting at 1.
+	Line   int
+	Column int
+	// Expected is the token that was wanted, or FFTok_init if any
+	// value would have done. Actual is the token that was found, or
+	// FFTok_error if the input could not be tokenized.
+	Expected FFTok
+	Actual   FFTok
+	// Path is the JSON path of the value being decoded, such as
+	// sources.data[3].exp_month. It is empty at the top level.
+	Path string
+	// Err describes errors found by the lexer, and is nil for
+	// unexpected tokens.
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	var msg string
+	switch {
+	case e.Err != nil:
+		msg = e.Err.Error()
+	case e.Expected == FFTok_init:
+		msg = fmt.Sprintf("wanted value token, but got token: %v", e.Actual)
+	default:
+		msg = fmt.Sprintf("wanted token: %v, but got token: %v", e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("ffjson: syntax error: %s offset=%d line=%d column=%d path=%s",
+		msg, e.Offset, e.Line, e.Column, e.Path)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalTypeError is returned for a JSON value that cannot be
+// stored in the Go value it is decoded into.
+type UnmarshalTypeError struct {
+	// Value describes the JSON value, such as "string" or "number 300".
+	Value string
+	// Type is the Go type that could not hold it.
+	Type string
+	// Offset, Line, Column and Path are as in SyntaxError.
+	Offset int64
+	Line   int
+	Column int
+	Path   string
+	// Err is the conversion error, if any.
+	Err error
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	msg := fmt.Sprintf("ffjson: cannot unmarshal %s into Go value of type %s offset=%d line=%d column=%d path=%s",
+		e.Value, e.Type, e.Offset, e.Line, e.Column, e.Path)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *UnmarshalTypeError) Unwrap() error {
+	return e.Err
+}
+
+// pathElem is a key, or an index if index is not negative.
+type pathElem struct {
+	key   string
+	index int
+}
+
+// PushKey records that the value of object member key is being
+// decoded. It is used by generated code to build error paths.
+func (ffl *FFLexer) PushKey(key string) {
+	ffl.path = append(ffl.path, pathElem{key: key, index: -1})
+}
+
+// PushIndex records that array element i is being decoded.
+func (ffl *FFLexer) PushIndex(i int) {
+	ffl.path = append(ffl.path, pathElem{index: i})
+}
+
+// PopPath undoes the last PushKey or PushIndex.
+func (ffl *FFLexer) PopPath() {
+	if n := len(ffl.path); n > 0 {
+		ffl.path = ffl.path[:n-1]
+	}
+}
+
+// Path returns the JSON path of the value being decoded.
+func (ffl *FFLexer) Path() string {
+	var b []byte
+	for i, p := range ffl.path {
+		if p.index >= 0 {
+			b = append(b, '[')
+			b = strconv.AppendInt(b, int64(p.index), 10)
+			b = append(b, ']')
+			continue
+		}
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = append(b, p.key...)
+	}
+	return string(b)
+}
+
+// position returns the input offset, line and column of the reader.
+func (ffl *FFLexer) position() (int64, int, int) {
+	line, char := ffl.reader.PosWithLine()
+	return ffl.reader.InputOffset(), line, char
+}
+
+// UnexpectedToken returns a *SyntaxError for finding tok where wanted
+// was expected. If tok is FFTok_error, the scan error is returned instead.
+func (ffl *FFLexer) UnexpectedToken(wanted, tok FFTok) error {
+	if tok == FFTok_error {
+		return ffl.ScanError()
+	}
+	offset, line, column := ffl.position()
+	return &SyntaxError{
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+		Expected: wanted,
+		Actual:   tok,
+		Path:     ffl.Path(),
+	}
+}
+
+// ScanError returns the error that made Scan return FFTok_error.
+// Malformed input gives a *SyntaxError; read errors and exceeded
+// Limits are returned wrapped as by WrapErr.
+func (ffl *FFLexer) ScanError() error {
+	err := ffl.BigError
+	if err == nil {
+		err = ffl.Error.ToError()
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	se, ok := err.(*SyntaxError)
+	if !ok {
+		if ffl.BigError != nil && err != io.ErrUnexpectedEOF {
+			return ffl.WrapErr(err)
+		}
+		se = &SyntaxError{Err: err}
+	}
+	se.Actual = FFTok_error
+	se.Offset, se.Line, se.Column = ffl.position()
+	se.Path = ffl.Path()
+	return se
+}
+
+// TypeErr returns a *UnmarshalTypeError for the value of tok, which
+// cannot be stored in a Go value of type typ. err is the conversion
+// error, if any.
+func (ffl *FFLexer) TypeErr(tok FFTok, typ string, err error) error {
+	var value string
+	switch tok {
+	case FFTok_string:
+		value = "string"
+	case FFTok_integer, FFTok_double:
+		value = "number " + ffl.Output.String()
+	case FFTok_bool:
+		value = "bool"
+	case FFTok_null:
+		value = "null"
+	case FFTok_left_bracket:
+		value = "object"
+	case FFTok_left_brace:
+		value = "array"
+	default:
+		return ffl.UnexpectedToken(FFTok_init, tok)
+	}
+
+	offset, line, column := ffl.position()
+	return &UnmarshalTypeError{
+		Value:  value,
+		Type:   typ,
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		Path:   ffl.Path(),
+		Err:    err,
+	}
+}
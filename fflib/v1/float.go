@@ -0,0 +1,157 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// NonFinitePolicy selects how NaN and infinite floats are encoded.
+// They have no JSON number representation.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError fails with a *json.UnsupportedValueError, like
+	// encoding/json.
+	NonFiniteError NonFinitePolicy = iota
+	// NonFiniteNull writes null.
+	NonFiniteNull
+	// NonFiniteString writes the string "NaN", "Infinity" or
+	// "-Infinity".
+	NonFiniteString
+)
+
+type nonFinitePolicer interface {
+	NonFinitePolicy() NonFinitePolicy
+}
+
+// NonFinitePolicyOf returns the NonFinitePolicy of buf, which is
+// NonFiniteError unless buf has a NonFinitePolicy method, such as
+// Buffer.NonFinitePolicy.
+func NonFinitePolicyOf(buf interface{}) NonFinitePolicy {
+	if p, ok := buf.(nonFinitePolicer); ok {
+		return p.NonFinitePolicy()
+	}
+	return NonFiniteError
+}
+
+// SetNonFinitePolicy selects what WriteFloat does with NaN and
+// infinite values.
+func (b *Buffer) SetNonFinitePolicy(p NonFinitePolicy) {
+	b.nonFinite = p
+}
+
+// NonFinitePolicy returns the policy set by SetNonFinitePolicy.
+func (b *Buffer) NonFinitePolicy() NonFinitePolicy {
+	return b.nonFinite
+}
+
+// NonFinitePolicy returns the policy of the underlying buffer.
+func (b *IndentBuffer) NonFinitePolicy() NonFinitePolicy {
+	return NonFinitePolicyOf(b.out)
+}
+
+var floatPool = sync.Pool{
+	New: func() interface{} { return new([64]byte) },
+}
+
+// WriteFloat writes f, of the given bit size, byte for byte as
+// encoding/json does: like ES6, exponents are only used for very
+// small and very large magnitudes. If quoted, the caller has already
+// opened a JSON string for the ",string" option.
+//
+// NaN and infinities are handled by the NonFinitePolicy of buf.
+func WriteFloat(buf EncodingBuffer, f float64, bits int, quoted bool) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return writeNonFinite(buf, f, bits, quoted)
+	}
+
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			fmt = 'e'
+		}
+	}
+
+	// The digits are built in a pooled array, since a local one
+	// would escape to the heap through buf.Write.
+	ap := floatPool.Get().(*[64]byte)
+	b := strconv.AppendFloat(ap[:0], f, fmt, -1, bits)
+	if fmt == 'e' {
+		// Clean up e-09 to e-9, as encoding/json does.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+	floatPool.Put(ap)
+	return nil
+}
+
+func writeNonFinite(buf EncodingBuffer, f float64, bits int, quoted bool) error {
+	switch NonFinitePolicyOf(buf) {
+	case NonFiniteNull:
+		// Inside the caller's quotes this is the string "null", which
+		// encoding/json and generated decoders read as null for
+		// ",string" fields.
+		buf.WriteString("null")
+		return nil
+	case NonFiniteString:
+		s := "NaN"
+		if math.IsInf(f, 1) {
+			s = "Infinity"
+		} else if math.IsInf(f, -1) {
+			s = "-Infinity"
+		}
+		if !quoted {
+			buf.WriteByte('"')
+		}
+		buf.WriteString(s)
+		if !quoted {
+			buf.WriteByte('"')
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(f)
+	if bits == 32 {
+		v = reflect.ValueOf(float32(f))
+	}
+	return &json.UnsupportedValueError{Value: v, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+}
+
+// WriteFloatFormat writes f as strconv.AppendFloat does with the
+// format fmt, which is 'e', 'f' or 'g', and the precision prec. The
+// exponents of 'e' and 'g', such as 1e+21, are valid JSON. NaN and
+// infinities are handled as by WriteFloat.
+func WriteFloatFormat(buf EncodingBuffer, f float64, fmt byte, prec, bits int, quoted bool) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return writeNonFinite(buf, f, bits, quoted)
+	}
+	ap := floatPool.Get().(*[64]byte)
+	buf.Write(strconv.AppendFloat(ap[:0], f, fmt, prec, bits))
+	floatPool.Put(ap)
+	return nil
+}
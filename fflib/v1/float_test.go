@@ -0,0 +1,195 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+var parityFloats = []float64{
+	0, math.Copysign(0, -1), 1, -1, 0.1, 1.5, 100, 123456789, 1e20,
+	1e21, -1e21, 1.2345e21, 1e100, 1e-6, 9.99e-7, 1e-7, -1e-7,
+	1.5e-9, 1e-10, 1e-300, 5e-324, math.MaxFloat64, math.SmallestNonzeroFloat64,
+	math.MaxFloat32, 1 << 53, 3.4e38, 0.000001234, 12345678.9,
+}
+
+func TestWriteFloatParity(t *testing.T) {
+	for _, f := range parityFloats {
+		var buf Buffer
+		if err := WriteFloat(&buf, f, 64, false); err != nil {
+			t.Fatalf("%v: %v", f, err)
+		}
+		want, _ := json.Marshal(f)
+		if buf.String() != string(want) {
+			t.Errorf("float64 %v: expected %s, got %s", f, want, buf.String())
+		}
+
+		f32 := float32(f)
+		if math.IsInf(float64(f32), 0) {
+			continue
+		}
+		buf.Reset()
+		if err := WriteFloat(&buf, float64(f32), 32, false); err != nil {
+			t.Fatalf("%v: %v", f32, err)
+		}
+		want, _ = json.Marshal(f32)
+		if buf.String() != string(want) {
+			t.Errorf("float32 %v: expected %s, got %s", f32, want, buf.String())
+		}
+	}
+}
+
+func TestWriteFloatParityRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	check := func(v interface{}, f float64, bits int) {
+		var buf Buffer
+		if err := WriteFloat(&buf, f, bits, false); err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		want, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		if buf.String() != string(want) {
+			t.Errorf("float%d %v: expected %s, got %s", bits, v, want, buf.String())
+		}
+	}
+
+	for i := 0; i < 100000; i++ {
+		// Random bit patterns cover every exponent, and random values
+		// of a random decimal magnitude cover the common range.
+		f32 := math.Float32frombits(r.Uint32())
+		if !math.IsNaN(float64(f32)) && !math.IsInf(float64(f32), 0) {
+			check(f32, float64(f32), 32)
+		}
+		f32 = float32(r.Float64() * math.Pow(10, float64(r.Intn(40)-20)))
+		check(f32, float64(f32), 32)
+
+		f64 := math.Float64frombits(r.Uint64())
+		if !math.IsNaN(f64) && !math.IsInf(f64, 0) {
+			check(f64, f64, 64)
+		}
+		f64 = r.NormFloat64() * math.Pow(10, float64(r.Intn(60)-30))
+		check(f64, f64, 64)
+	}
+}
+
+func TestAppendFloat(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var buf Buffer
+	for i := 0; i < 10000; i++ {
+		f := math.Float64frombits(r.Uint64())
+		for _, fmt := range []byte{'e', 'f', 'g', 'b'} {
+			for _, prec := range []int{-1, 0, 3, 17} {
+				for _, bits := range []int{32, 64} {
+					buf.Reset()
+					AppendFloat(&buf, f, fmt, prec, bits)
+					if want := strconv.FormatFloat(f, fmt, prec, bits); buf.String() != want {
+						t.Fatalf("%v %c %d %d: expected %s, got %s", f, fmt, prec, bits, want, buf.String())
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWriteFloatNonFinite(t *testing.T) {
+	for _, tc := range []struct {
+		f      float64
+		str    string
+		errStr string
+	}{
+		{math.NaN(), `"NaN"`, "NaN"},
+		{math.Inf(1), `"Infinity"`, "+Inf"},
+		{math.Inf(-1), `"-Infinity"`, "-Inf"},
+	} {
+		var buf Buffer
+		err := WriteFloat(&buf, tc.f, 64, false)
+		var ue *json.UnsupportedValueError
+		if !errors.As(err, &ue) || ue.Str != tc.errStr || buf.Len() != 0 {
+			t.Errorf("%v: expected an UnsupportedValueError, got %v %q", tc.f, err, buf.String())
+		}
+		if _, jerr := json.Marshal(tc.f); err == nil || jerr.Error() != err.Error() {
+			t.Errorf("%v: expected %v, got %v", tc.f, jerr, err)
+		}
+
+		buf.SetNonFinitePolicy(NonFiniteNull)
+		if err := WriteFloat(&buf, tc.f, 32, false); err != nil || buf.String() != "null" {
+			t.Errorf("%v, NonFiniteNull: got %q %v", tc.f, buf.String(), err)
+		}
+
+		buf.Reset()
+		buf.SetNonFinitePolicy(NonFiniteString)
+		if err := WriteFloat(&buf, tc.f, 64, false); err != nil || buf.String() != tc.str {
+			t.Errorf("%v, NonFiniteString: expected %s, got %q %v", tc.f, tc.str, buf.String(), err)
+		}
+		buf.Reset()
+		buf.WriteByte('"')
+		if err := WriteFloat(&buf, tc.f, 64, true); err != nil || buf.String() != tc.str[:len(tc.str)-1] {
+			t.Errorf("%v, NonFiniteString quoted: got %q %v", tc.f, buf.String(), err)
+		}
+	}
+
+	var buf Buffer
+	buf.SetNonFinitePolicy(NonFiniteNull)
+	ib := NewIndentBuffer(&buf, "", "  ")
+	if err := WriteFloat(ib, math.NaN(), 64, false); err != nil {
+		t.Errorf("IndentBuffer ignored the policy of the underlying buffer: %v", err)
+	}
+}
+
+func TestWriteFloatFormat(t *testing.T) {
+	for _, tc := range []struct {
+		f    float64
+		fmt  byte
+		prec int
+		bits int
+	}{
+		{19.999, 'f', 2, 64},
+		{0.125, 'f', 2, 64},
+		{math.Copysign(0, -1), 'f', 2, 64},
+		{1234.5, 'e', 3, 32},
+		{1e21, 'e', -1, 64},
+		{0.000012, 'g', -1, 64},
+		{123456789, 'g', 4, 64},
+	} {
+		var buf Buffer
+		if err := WriteFloatFormat(&buf, tc.f, tc.fmt, tc.prec, tc.bits, false); err != nil {
+			t.Fatalf("%v: %v", tc.f, err)
+		}
+		want := strconv.FormatFloat(tc.f, tc.fmt, tc.prec, tc.bits)
+		if buf.String() != want {
+			t.Errorf("%v %c %d: expected %s, got %s", tc.f, tc.fmt, tc.prec, want, buf.String())
+		}
+		var v float64
+		if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+			t.Errorf("%s is not a JSON number: %v", buf.String(), err)
+		}
+	}
+
+	var buf Buffer
+	buf.SetNonFinitePolicy(NonFiniteNull)
+	if err := WriteFloatFormat(&buf, math.Inf(1), 'f', 2, 64, false); err != nil || buf.String() != "null" {
+		t.Errorf("NonFiniteNull: got %q %v", buf.String(), err)
+	}
+}
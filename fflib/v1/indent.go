@@ -0,0 +1,213 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"errors"
+	"io"
+)
+
+var errIndentRewind = errors.New("fflib.v1.IndentBuffer: can only rewind a trailing comma or whitespace")
+
+// IndentBuffer is an EncodingBuffer that indents the compact JSON
+// written to it, the same way json.Indent does, and passes the
+// result on to an underlying EncodingBuffer.
+//
+// Generated code, nested generated types and buf.Encode fallbacks
+// all write through the same IndentBuffer, so the whole document
+// is indented consistently.
+type IndentBuffer struct {
+	out    EncodingBuffer
+	prefix string
+	indent string
+
+	depth    int
+	inString bool
+	escaped  bool
+	// open is set after '{' or '[', until the first element is written.
+	open bool
+	// comma is set for a ',' that has not been written yet, so it
+	// can still be rewound and is followed by a newline when it is.
+	comma bool
+	// skipped is set when the last byte written was dropped whitespace.
+	skipped bool
+	scratch Buffer
+}
+
+// NewIndentBuffer returns an IndentBuffer writing to out. Each
+// element of an object or array begins on a new line starting with
+// prefix followed by one or more copies of indent.
+func NewIndentBuffer(out EncodingBuffer, prefix, indent string) *IndentBuffer {
+	return &IndentBuffer{out: out, prefix: prefix, indent: indent}
+}
+
+func (b *IndentBuffer) newline() {
+	b.out.WriteByte('\n')
+	b.out.WriteString(b.prefix)
+	for i := 0; i < b.depth; i++ {
+		b.out.WriteString(b.indent)
+	}
+}
+
+// flush writes any delayed comma and line break before a new element.
+func (b *IndentBuffer) flush() {
+	if b.comma {
+		b.out.WriteByte(',')
+		b.comma = false
+		b.newline()
+	} else if b.open {
+		b.open = false
+		b.newline()
+	}
+}
+
+func (b *IndentBuffer) Write(p []byte) (int, error) {
+	start := 0
+	for i, c := range p {
+		if b.inString {
+			if b.escaped {
+				b.escaped = false
+			} else if c == '\\' {
+				b.escaped = true
+			} else if c == '"' {
+				b.inString = false
+			}
+			continue
+		}
+
+		if start < i {
+			b.out.Write(p[start:i])
+		}
+		start = i + 1
+		b.writeByte(c)
+	}
+
+	if start < len(p) {
+		b.out.Write(p[start:])
+	}
+	return len(p), nil
+}
+
+func (b *IndentBuffer) writeByte(c byte) {
+	b.skipped = false
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		b.skipped = true
+	case ',':
+		b.comma = true
+	case ':':
+		b.out.WriteString(": ")
+	case '{', '[':
+		b.flush()
+		b.out.WriteByte(c)
+		b.depth++
+		b.open = true
+	case '}', ']':
+		b.depth--
+		b.comma = false
+		if b.open {
+			b.open = false
+		} else {
+			b.newline()
+		}
+		b.out.WriteByte(c)
+	default:
+		b.flush()
+		if c == '"' {
+			b.inString = true
+		}
+		b.out.WriteByte(c)
+	}
+}
+
+func (b *IndentBuffer) WriteByte(c byte) error {
+	if b.inString {
+		b.Write([]byte{c})
+		return nil
+	}
+	b.writeByte(c)
+	return nil
+}
+
+func (b *IndentBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// Rewind drops the last n bytes written. Since the output has already
+// been indented, only a trailing comma or whitespace can be rewound.
+func (b *IndentBuffer) Rewind(n int) error {
+	if n != 1 {
+		return errIndentRewind
+	}
+	if b.comma {
+		b.comma = false
+		return nil
+	}
+	if b.skipped {
+		b.skipped = false
+		return nil
+	}
+	return errIndentRewind
+}
+
+// Encode falls back to encoding/json for v, indenting its output.
+func (b *IndentBuffer) Encode(v interface{}) error {
+	b.scratch.Reset()
+	b.scratch.SetEscapeHTML(EscapeHTML(b.out))
+	err := b.scratch.Encode(v)
+	if err != nil {
+		return err
+	}
+	b.Write(b.scratch.Bytes())
+	return nil
+}
+
+// EscapeHTML reports whether the underlying buffer escapes <, > and &.
+func (b *IndentBuffer) EscapeHTML() bool {
+	return EscapeHTML(b.out)
+}
+
+func (b *IndentBuffer) WriteTo(w io.Writer) (int64, error) {
+	return b.out.WriteTo(w)
+}
+
+func (b *IndentBuffer) Grow(n int) {
+	b.out.Grow(n)
+}
+
+// Truncate discards all but the first n bytes of the underlying buffer.
+// The indentation state is reset, so it should only be used to start over.
+func (b *IndentBuffer) Truncate(n int) {
+	b.out.Truncate(n)
+	b.reset()
+}
+
+// Reset resets the buffer so it has no content.
+func (b *IndentBuffer) Reset() {
+	b.out.Reset()
+	b.reset()
+}
+
+func (b *IndentBuffer) reset() {
+	b.depth = 0
+	b.inString = false
+	b.escaped = false
+	b.open = false
+	b.comma = false
+	b.skipped = false
+}
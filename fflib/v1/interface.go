@@ -0,0 +1,150 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+)
+
+// NumberMode selects how numbers are decoded into interface{} values.
+type NumberMode int
+
+const (
+	// NumberFloat64 decodes all numbers as float64, like encoding/json.
+	NumberFloat64 NumberMode = iota
+	// NumberJSON decodes all numbers as json.Number, like json.Decoder.UseNumber.
+	NumberJSON
+	// NumberInt64 decodes integer tokens as int64 and all other numbers
+	// as float64. Integers that overflow int64 are decoded as float64.
+	NumberInt64
+)
+
+// DecodeInterface decodes the value starting at tok the same way
+// encoding/json decodes into an empty interface: objects become
+// map[string]interface{}, arrays []interface{}, and numbers follow
+// the lexer's NumberMode.
+func (ffl *FFLexer) DecodeInterface(tok FFTok) (interface{}, error) {
+	switch tok {
+	case FFTok_null:
+		return nil, nil
+	case FFTok_bool:
+		return ffl.Output.Bytes()[0] == 't', nil
+	case FFTok_string:
+		return ffl.Output.String(), nil
+	case FFTok_integer, FFTok_double:
+		return ffl.decodeNumber(tok)
+	case FFTok_left_bracket:
+		return ffl.decodeObject()
+	case FFTok_left_brace:
+		return ffl.decodeArray()
+	}
+	return nil, ffl.UnexpectedToken(FFTok_init, tok)
+}
+
+func (ffl *FFLexer) decodeNumber(tok FFTok) (interface{}, error) {
+	switch ffl.NumberMode {
+	case NumberJSON:
+		return json.Number(ffl.Output.String()), nil
+	case NumberInt64:
+		if tok == FFTok_integer {
+			i, err := ParseInt(ffl.Output.Bytes(), 10, 64)
+			if err == nil {
+				return i, nil
+			}
+		}
+	}
+	f, err := ParseFloat(ffl.Output.Bytes(), 64)
+	if err != nil {
+		return nil, ffl.TypeErr(tok, "float64", err)
+	}
+	return f, nil
+}
+
+func (ffl *FFLexer) decodeObject() (interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		tok := ffl.Scan()
+		if tok == FFTok_right_bracket && len(m) == 0 {
+			return m, nil
+		}
+		if tok != FFTok_string {
+			return nil, ffl.unexpected(tok, FFTok_string)
+		}
+		key := ffl.Output.String()
+		keyOffset := ffl.TokenOffset()
+
+		tok = ffl.Scan()
+		if tok != FFTok_colon {
+			return nil, ffl.unexpected(tok, FFTok_colon)
+		}
+
+		ffl.PushKey(key)
+		tok = ffl.Scan()
+		if _, dup := m[key]; dup && ffl.DuplicateKeys != DuplicateLastWins {
+			err := ffl.DuplicateKey(key, keyOffset, tok)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			v, err := ffl.DecodeInterface(tok)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		ffl.PopPath()
+
+		tok = ffl.Scan()
+		if tok == FFTok_right_bracket {
+			return m, nil
+		}
+		if tok != FFTok_comma {
+			return nil, ffl.unexpected(tok, FFTok_comma)
+		}
+	}
+}
+
+func (ffl *FFLexer) decodeArray() (interface{}, error) {
+	a := make([]interface{}, 0)
+	for {
+		tok := ffl.Scan()
+		if tok == FFTok_right_brace && len(a) == 0 {
+			return a, nil
+		}
+
+		ffl.PushIndex(len(a))
+		v, err := ffl.DecodeInterface(tok)
+		if err != nil {
+			return nil, err
+		}
+		ffl.PopPath()
+		a = append(a, v)
+
+		tok = ffl.Scan()
+		if tok == FFTok_right_brace {
+			return a, nil
+		}
+		if tok != FFTok_comma {
+			return nil, ffl.unexpected(tok, FFTok_comma)
+		}
+	}
+}
+
+func (ffl *FFLexer) unexpected(tok FFTok, wanted FFTok) error {
+	return ffl.UnexpectedToken(wanted, tok)
+}
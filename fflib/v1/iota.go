@@ -24,6 +24,7 @@ package v1
 
 import (
 	"io"
+	"sync"
 )
 
 const (
@@ -74,6 +75,10 @@ func FormatBits(scratch *FormatBitsScratch, dst FormatBitsWriter, u uint64, base
 	FormatBits2(dst, u, base, neg)
 }
 
+var bitsPool = sync.Pool{
+	New: func() interface{} { return new([64 + 1]byte) },
+}
+
 // FormatBits2 computes the string representation of u in the given base.
 // If neg is set, u is treated as negative int64 value. If append_ is
 // set, the string is appended to dst and the resulting byte slice is
@@ -85,7 +90,7 @@ func FormatBits2(dst FormatBitsWriter, u uint64, base int, neg bool) {
 		panic("strconv: illegal AppendInt/FormatInt base")
 	}
 	// fast path for small common numbers
-	if u <= 10 {
+	if u <= 10 && base == 10 || u < 10 && u < uint64(base) {
 		if neg {
 			dst.WriteByte('-')
 		}
@@ -95,8 +100,10 @@ func FormatBits2(dst FormatBitsWriter, u uint64, base int, neg bool) {
 
 	// 2 <= base && base <= len(digits)
 
-	var a = makeSlice(65)
-	//	var a [64 + 1]byte // +1 for sign of 64bit value in base 2
+	// The digits are built in a pooled array, since a local one
+	// would escape to the heap through dst.Write.
+	ap := bitsPool.Get().(*[64 + 1]byte) // +1 for sign of 64bit value in base 2
+	a := ap[:]
 	i := len(a)
 
 	if neg {
@@ -155,7 +162,7 @@ func FormatBits2(dst FormatBitsWriter, u uint64, base int, neg bool) {
 
 	dst.Write(a[i:])
 
-	Pool(a)
+	bitsPool.Put(ap)
 
 	return
 }
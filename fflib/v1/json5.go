@@ -0,0 +1,355 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// json5State is what Scan remembers between tokens in SyntaxJSON5.
+type json5State struct {
+	// objects records whether each open container is an object.
+	objects []bool
+	// last is the previous token returned by Scan.
+	last FFTok
+	// comma is set when a ',' has been read that is not a trailing
+	// comma, so the next Scan returns it. commaOffset is its offset.
+	comma       bool
+	commaOffset int64
+}
+
+func (s *json5State) reset() {
+	s.objects = s.objects[:0]
+	s.last = FFTok_init
+	s.comma = false
+}
+
+func (s *json5State) track(tok FFTok) {
+	switch tok {
+	case FFTok_left_bracket, FFTok_left_brace:
+		s.objects = append(s.objects, tok == FFTok_left_bracket)
+	case FFTok_right_bracket, FFTok_right_brace:
+		if n := len(s.objects); n > 0 {
+			s.objects = s.objects[:n-1]
+		}
+	}
+	s.last = tok
+}
+
+// wantKey reports whether the next token is an object key.
+func (s *json5State) wantKey() bool {
+	n := len(s.objects)
+	return n > 0 && s.objects[n-1] &&
+		(s.last == FFTok_left_bracket || s.last == FFTok_comma)
+}
+
+// afterValue reports whether a ',' read now may be a trailing comma.
+func (s *json5State) afterValue() bool {
+	if len(s.objects) == 0 {
+		return false
+	}
+	switch s.last {
+	case FFTok_string, FFTok_integer, FFTok_double, FFTok_bool, FFTok_null,
+		FFTok_right_bracket, FFTok_right_brace:
+		return true
+	}
+	return false
+}
+
+var errJSON5Comment = errors.New("ffjson: invalid comment")
+
+// skipJSON5 skips whitespace, comments and trailing commas. A comma
+// that turns out not to be trailing sets json5.comma instead.
+func (ffl *FFLexer) skipJSON5() error {
+	if ffl.json5State.comma {
+		return nil
+	}
+	for {
+		c, err := ffl.reader.ReadByteNoWS()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c == '/':
+			if ffl.lexComment() != FFTok_comment {
+				return errJSON5Comment
+			}
+			continue
+		case c == ',' && !ffl.json5State.comma && ffl.json5State.afterValue():
+			ffl.json5State.comma = true
+			ffl.json5State.commaOffset = ffl.reader.InputOffset() - 1
+			continue
+		case (c == ']' || c == '}') && ffl.json5State.comma:
+			// a trailing comma.
+			ffl.json5State.comma = false
+		}
+
+		ffl.reader.UnreadByte()
+		return nil
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c == '_' || c == '$' || c >= utf8.RuneSelf
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) ||
+		unicode.Is(unicode.Pc, r) || r == '$' || r == '\u200c' || r == '\u200d'
+}
+
+// lexIdentifier lexes an unquoted object key.
+func (ffl *FFLexer) lexIdentifier() FFTok {
+	ffl.reader.Mark()
+	for {
+		c, err := ffl.readNumByte()
+		if err != nil {
+			return FFTok_error
+		}
+		if c < 0 {
+			break
+		}
+		if c < utf8.RuneSelf {
+			if isIdentStart(byte(c)) || c >= '0' && c <= '9' {
+				continue
+			}
+			ffl.unreadByte()
+			break
+		}
+
+		// Make sure the whole sequence is in the buffer.
+		ffl.unreadByte()
+		err = ffl.reader.ensure(utf8.UTFMax)
+		if err != nil {
+			ffl.Error = FFErr_io
+			ffl.BigError = err
+			return FFTok_error
+		}
+		r, size := utf8.DecodeRune(ffl.reader.buffer[ffl.reader.head:ffl.reader.tail])
+		if r == utf8.RuneError && size == 1 || !isIdentRune(r) {
+			ffl.Error = FFErr_invalid_char
+			return FFTok_error
+		}
+		ffl.reader.head += size
+	}
+
+	ident := ffl.reader.Marked()
+	if r, _ := utf8.DecodeRune(ident); unicode.IsDigit(r) || unicode.IsMark(r) {
+		ffl.Error = FFErr_invalid_char
+		return FFTok_error
+	}
+	if ffl.captureAll {
+		WriteJson(ffl.Output, ident)
+	} else {
+		ffl.Output.Write(ident)
+	}
+	return FFTok_string
+}
+
+func isJSON5NumberByte(c int) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c == '.' || c == '+' || c == '-'
+}
+
+// lexJSON5Number lexes a JSON5 number, and writes it to Output as
+// a JSON number, or as Infinity, -Infinity or NaN.
+func (ffl *FFLexer) lexJSON5Number() FFTok {
+	ffl.reader.Mark()
+	for {
+		c, err := ffl.readNumByte()
+		if err != nil {
+			return FFTok_error
+		}
+		if c < 0 {
+			break
+		}
+		if !isJSON5NumberByte(c) {
+			ffl.unreadByte()
+			break
+		}
+	}
+	text := ffl.reader.Marked()
+
+	s := text
+	neg := false
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+
+	switch {
+	case string(s) == "Infinity":
+		if neg {
+			ffl.Output.WriteByte('-')
+		}
+		ffl.Output.Write(s)
+		return FFTok_double
+	case string(s) == "NaN":
+		ffl.Output.Write(s)
+		return FFTok_double
+	case len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X'):
+		v, err := ParseUint(s[2:], 16, 64)
+		if err != nil {
+			ffl.BigError = &SyntaxError{Err: fmt.Errorf("lex_invalid_hex_number: %s", text)}
+			return FFTok_error
+		}
+		if neg {
+			ffl.Output.WriteByte('-')
+		}
+		var b [20]byte
+		ffl.Output.Write(strconv.AppendUint(b[:0], v, 10))
+		return FFTok_integer
+	}
+
+	tok := FFTok_integer
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	whole := s[:i]
+	if len(whole) > 1 && whole[0] == '0' {
+		ffl.Error = FFErr_invalid_char
+		return FFTok_error
+	}
+
+	var frac []byte
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		frac = s[start:i]
+		tok = FFTok_double
+	}
+	if len(whole) == 0 && len(frac) == 0 {
+		ffl.Error = FFErr_missing_integer_after_decimal
+		if tok == FFTok_integer {
+			ffl.Error = FFErr_invalid_char
+		}
+		return FFTok_error
+	}
+
+	var exp []byte
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		start := i
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		digits := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == digits {
+			ffl.Error = FFErr_missing_integer_after_exponent
+			return FFTok_error
+		}
+		exp = s[start:i]
+		tok = FFTok_double
+	}
+	if i != len(s) {
+		ffl.Error = FFErr_invalid_char
+		return FFTok_error
+	}
+
+	if neg {
+		ffl.Output.WriteByte('-')
+	}
+	if len(whole) == 0 {
+		ffl.Output.WriteByte('0')
+	} else {
+		ffl.Output.Write(whole)
+	}
+	if len(frac) > 0 {
+		ffl.Output.WriteByte('.')
+		ffl.Output.Write(frac)
+	}
+	ffl.Output.Write(exp)
+	return tok
+}
+
+// handleJSON5Escape handles the escapes JSON5 adds to JSON. c is
+// the escaped byte at j-1. Like handleEscaped, it returns the index
+// to continue scanning at.
+func (r *ffReader) handleJSON5Escape(c byte, j int, out DecodingBuffer) (int, error) {
+	out.Write(r.buffer[r.head : j-2])
+
+	switch {
+	case c == 'x':
+		if j+2 > r.tail ||
+			byteLookupTable[r.buffer[j]]&cVHC == 0 || byteLookupTable[r.buffer[j+1]]&cVHC == 0 {
+			return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_hex_char: %q", r.buffer[j-2:min(j+2, r.tail)])}
+		}
+		v, _ := ParseUint(r.buffer[j:j+2], 16, 8)
+		out.WriteRune(rune(v))
+		j += 2
+	case c == '0':
+		if j < r.tail && r.buffer[j] >= '0' && r.buffer[j] <= '9' {
+			return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_escaped_char: %v", c)}
+		}
+		out.WriteByte(0)
+	case c >= '1' && c <= '9':
+		return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_escaped_char: %v", c)}
+	case c == 'v':
+		out.WriteByte('\v')
+	case c == '\n':
+		// a line continuation.
+	case c == '\r':
+		if j < r.tail && r.buffer[j] == '\n' {
+			j++
+		}
+	case c >= utf8.RuneSelf:
+		ru, size := utf8.DecodeRune(r.buffer[j-1 : r.tail])
+		if ru == utf8.RuneError && size == 1 {
+			return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_utf8: %#x", c)}
+		}
+		if ru != '\u2028' && ru != '\u2029' {
+			// anything but a line continuation.
+			out.WriteRune(ru)
+		}
+		j += size - 1
+	default:
+		// any other character, such as \', stands for itself.
+		out.WriteByte(c)
+	}
+
+	r.head = j
+	return j, nil
+}
+
+// peekJSON5 is PeekByte in SyntaxJSON5.
+func (ffl *FFLexer) peekJSON5() (byte, error) {
+	err := ffl.skipJSON5()
+	if ffl.json5State.comma {
+		return ',', nil
+	}
+	if err == errJSON5Comment {
+		return 0, ffl.ScanError()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ffl.reader.buffer[ffl.reader.head], nil
+}
@@ -0,0 +1,215 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func json5Lexers(input string) []*FFLexer {
+	lexers := []*FFLexer{
+		NewFFLexerBytes([]byte(input)),
+		NewFFLexer(iotest.OneByteReader(strings.NewReader(input))),
+	}
+	for _, l := range lexers {
+		l.Syntax = SyntaxJSON5
+	}
+	return lexers
+}
+
+func TestJSON5Tokens(t *testing.T) {
+	input := `// settings
+	{
+		unquoted: 'single "quoted"',
+		$id_2: [1, 2, /* three */ 3,],
+		"nested": {a: null, b: true,},
+		'key': 'it\'s',
+	}`
+	want := []string{
+		"{", "unquoted", ":", `single "quoted"`, ",", "$id_2", ":",
+		"[", "1", ",", "2", ",", "3", "]", ",",
+		"nested", ":", "{", "a", ":", "null", ",", "b", ":", "true", "}", ",",
+		"key", ":", "it's", "}",
+	}
+
+	for _, l := range json5Lexers(input) {
+		var got []string
+		for {
+			tok := l.Scan()
+			if tok == FFTok_eof {
+				break
+			}
+			if tok == FFTok_error {
+				t.Fatalf("unexpected error: %v", l.ScanError())
+			}
+			switch tok {
+			case FFTok_left_bracket:
+				got = append(got, "{")
+			case FFTok_right_bracket:
+				got = append(got, "}")
+			case FFTok_left_brace:
+				got = append(got, "[")
+			case FFTok_right_brace:
+				got = append(got, "]")
+			case FFTok_colon:
+				got = append(got, ":")
+			case FFTok_comma:
+				got = append(got, ",")
+			default:
+				got = append(got, l.Output.String())
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestJSON5Numbers(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		tok   FFTok
+		out   string
+	}{
+		{`0x1F`, FFTok_integer, "31"},
+		{`-0XfF`, FFTok_integer, "-255"},
+		{`+1`, FFTok_integer, "1"},
+		{`.5`, FFTok_double, "0.5"},
+		{`5.`, FFTok_double, "5"},
+		{`-.5e3`, FFTok_double, "-0.5e3"},
+		{`Infinity`, FFTok_double, "Infinity"},
+		{`-Infinity`, FFTok_double, "-Infinity"},
+		{`NaN`, FFTok_double, "NaN"},
+		{`12`, FFTok_integer, "12"},
+	} {
+		for _, l := range json5Lexers("[" + tc.input + "]") {
+			l.Scan()
+			tok := l.Scan()
+			if tok != tc.tok || l.Output.String() != tc.out {
+				t.Errorf("%s: expected %v %s, got %v %s", tc.input, tc.tok, tc.out, tok, l.Output)
+			}
+		}
+	}
+
+	l := NewFFLexerBytes([]byte(`-Infinity`))
+	l.Syntax = SyntaxJSON5
+	l.Scan()
+	f, err := ParseFloat(l.Output.Bytes(), 64)
+	if err != nil || !math.IsInf(f, -1) {
+		t.Errorf("expected -Inf, got %v %v", f, err)
+	}
+
+	for _, input := range []string{`01`, `0x`, `1.2.3`, `.`, `+`, `Inf`, `0x10000000000000000`} {
+		l := NewFFLexerBytes([]byte(input))
+		l.Syntax = SyntaxJSON5
+		if tok := l.Scan(); tok != FFTok_error {
+			t.Errorf("%s: expected error, got %v %s", input, tok, l.Output)
+		}
+	}
+}
+
+func TestJSON5Strings(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		out   string
+	}{
+		{`'a"b'`, `a"b`},
+		{`"a'b"`, `a'b`},
+		{`'\x41\v\0'`, "A\v\x00"},
+		{"'line \\\ncontinued'", "line continued"},
+		{"'line \\\r\ncontinued'", "line continued"},
+		{`'\q'`, "q"},
+		{"'tab\there'", "tab\there"},
+	} {
+		for _, l := range json5Lexers(tc.input) {
+			tok := l.Scan()
+			if tok != FFTok_string || l.Output.String() != tc.out {
+				t.Errorf("%q: expected %q, got %v %q", tc.input, tc.out, tok, l.Output)
+			}
+		}
+	}
+
+	for _, input := range []string{`'\1'`, `'\x4'`, "'new\nline'", `'unterminated`} {
+		l := NewFFLexerBytes([]byte(input))
+		l.Syntax = SyntaxJSON5
+		if tok := l.Scan(); tok != FFTok_error {
+			t.Errorf("%q: expected error, got %v %q", input, tok, l.Output)
+		}
+	}
+}
+
+func TestJSON5Default(t *testing.T) {
+	// Without SyntaxJSON5 the extensions are still errors.
+	for _, input := range []string{`'a'`, `+1`, `.5`, `Infinity`, `NaN`} {
+		l := NewFFLexerBytes([]byte(input))
+		if tok := l.Scan(); tok != FFTok_error {
+			t.Errorf("%s: expected error, got %v", input, tok)
+		}
+	}
+
+	l := NewFFLexerBytes([]byte(`{a: 1}`))
+	l.Scan()
+	if tok := l.Scan(); tok != FFTok_error {
+		t.Errorf("unquoted key: expected error, got %v", tok)
+	}
+}
+
+func TestJSON5Capture(t *testing.T) {
+	for _, l := range json5Lexers(`{a: ['x', 0x10, .5,], /* c */ b: {},}`) {
+		b, err := l.CaptureField(l.Scan())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != `{"a":["x",16,0.5],"b":{}}` {
+			t.Errorf("unexpected capture: %s", b)
+		}
+	}
+
+	if err := Validate([]byte(`{a: [1,,]}`), SyntaxJSON5); err == nil {
+		t.Error("double comma accepted")
+	}
+}
+
+func TestJSON5TokenAPI(t *testing.T) {
+	for _, l := range json5Lexers(`{name: 'x', size: 0x10, /* c */ }`) {
+		if kind, err := l.Next(); err != nil || kind != KindObjectStart {
+			t.Fatalf("expected {, got %v %v", kind, err)
+		}
+		var keys []string
+		for l.More() {
+			key, err := l.ReadString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, key)
+			if err := l.Skip(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if kind, err := l.Next(); err != nil || kind != KindObjectEnd {
+			t.Fatalf("expected }, got %v %v", kind, err)
+		}
+		if !reflect.DeepEqual(keys, []string{"name", "size"}) {
+			t.Errorf("unexpected keys %q", keys)
+		}
+	}
+}
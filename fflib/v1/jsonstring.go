@@ -28,6 +28,7 @@ import (
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
+	"unsafe"
 )
 
 const hex = "0123456789abcdef"
@@ -38,14 +39,31 @@ type JsonStringWriter interface {
 	stringWriter
 }
 
+type htmlEscaper interface {
+	EscapeHTML() bool
+}
+
+// EscapeHTML reports whether JSON strings written to buf should have
+// <, > and & escaped. This is true unless buf has an EscapeHTML method,
+// such as Buffer.EscapeHTML, that says otherwise.
+func EscapeHTML(buf interface{}) bool {
+	if e, ok := buf.(htmlEscaper); ok {
+		return e.EscapeHTML()
+	}
+	return true
+}
+
 func WriteJsonString(buf JsonStringWriter, s string) {
-	WriteJson(buf, []byte(s))
+	// Writers must not modify or retain the slice, as documented
+	// by io.Writer, so s can be passed on without a copy.
+	WriteJson(buf, unsafe.Slice(unsafe.StringData(s), len(s)))
 }
 
 /**
  * Function ported from encoding/json: func (e *encodeState) string(s string) (int, error)
  */
 func WriteJson(buf JsonStringWriter, s []byte) {
+	escapeHTML := EscapeHTML(buf)
 	buf.WriteByte('"')
 	start := 0
 	for i := 0; i < len(s); {
@@ -56,7 +74,7 @@ func WriteJson(buf JsonStringWriter, s []byte) {
 					continue
 				}
 			*/
-			if lt[b] == true {
+			if lt[b] == true || (!escapeHTML && (b == '<' || b == '>' || b == '&')) {
 				i++
 				continue
 			}
@@ -92,7 +110,7 @@ func WriteJson(buf JsonStringWriter, s []byte) {
 			if start < i {
 				buf.Write(s[start:i])
 			}
-			buf.WriteString(`\ufffd`)
+			writeInvalidUTF8(buf, s, i)
 			i += size
 			start = i
 			continue
@@ -291,7 +309,7 @@ var lt [256]bool = [256]bool{
 	true,  /* 35 */
 	true,  /* 36 */
 	true,  /* 37 */
-	true,  /* 38 */
+	false, /* 38 */
 	true,  /* 39 */
 	true,  /* 40 */
 	true,  /* 41 */
@@ -313,9 +331,9 @@ var lt [256]bool = [256]bool{
 	true,  /* 57 */
 	true,  /* 58 */
 	true,  /* 59 */
-	true,  /* 60 */
+	false, /* 60 */
 	true,  /* 61 */
-	true,  /* 62 */
+	false, /* 62 */
 	true,  /* 63 */
 	true,  /* 64 */
 	true,  /* 65 */
@@ -50,6 +50,10 @@ const (
 	FFParse_after_value
 )
 
+// FFTok is a token returned by Scan. Despite their names,
+// FFTok_left_bracket and FFTok_right_bracket are '{' and '}', and
+// FFTok_left_brace and FFTok_right_brace are '[' and ']'. Code outside
+// of generated decoders should prefer the Kind returned by Next.
 type FFTok int
 
 const (
@@ -72,7 +76,8 @@ const (
 
 	FFTok_string FFTok = iota
 
-	/* comment tokens are not currently returned to the parser, ever */
+	/* comment tokens are only returned in SyntaxDefault, where
+	 * generated decoders reject them */
 	FFTok_comment FFTok = iota
 )
 
@@ -101,10 +106,44 @@ type FFLexer struct {
 	Token    FFTok
 	Error    FFErr
 	BigError error
+
+	// DisallowUnknownFields makes generated decoders return an
+	// UnknownFieldError when an object key matches no field.
+	DisallowUnknownFields bool
+
+	// NumberMode selects how numbers are decoded into interface{} values.
+	NumberMode NumberMode
+
+	// Limits bounds the input the lexer accepts. Exceeding one of
+	// them makes Scan fail with a *LimitError in BigError.
+	Limits Limits
+
+	// Syntax selects the grammar Scan accepts.
+	Syntax Syntax
+
+	// UTF8Policy selects what Scan does with invalid UTF-8 in strings.
+	UTF8Policy UTF8Policy
+
+	// DuplicateKeys selects what generated decoders and DecodeInterface
+	// do with a key that appears twice in an object.
+	DuplicateKeys DuplicateKeyPolicy
+
 	// TODO: convert all of this to an interface
 	lastCurrentChar int
 	captureAll      bool
 	buf             Buffer
+	tokenOffset     int64
+	// path holds the keys and indexes leading to the value being decoded.
+	path []pathElem
+	// depth and elements track nesting for Limits.
+	depth    int
+	elements []int
+	// frames holds the objects and arrays opened through Next.
+	frames []frame
+	// pending is set by Start when Next should return Token again.
+	pending bool
+	// json5State is only used in SyntaxJSON5.
+	json5State json5State
 }
 
 func NewFFLexer(input io.Reader) *FFLexer {
@@ -114,16 +153,82 @@ func NewFFLexer(input io.Reader) *FFLexer {
 		reader: newffReader(input),
 		Output: &Buffer{},
 	}
+	fl.reader.limits = &fl.Limits
+	fl.reader.syntax = &fl.Syntax
+	fl.reader.utf8 = &fl.UTF8Policy
 	// TODO: guess size?
 	fl.Output.Grow(64)
 	return fl
 }
 
+// ReaderOptions tunes how a lexer reads from an io.Reader.
+type ReaderOptions struct {
+	// BufferSize is the least initial size of the read buffer. If
+	// zero, it is chosen from the sizes of earlier inputs. Pooled
+	// buffers may be larger, and the buffer grows as needed to hold
+	// a whole token.
+	BufferSize int
+	// SizeHint is the expected size of the input, such as the
+	// Content-Length of an HTTP request. If set, the buffer is made
+	// large enough to read it all at once, up to Limits.MaxInputSize
+	// and 4 MB. Since the hint may not be trusted, a larger input
+	// grows the buffer as it is read.
+	SizeHint int64
+}
+
+// NewFFLexerOptions returns a lexer reading from input with opts.
+func NewFFLexerOptions(input io.Reader, opts ReaderOptions) *FFLexer {
+	fl := NewFFLexer(input)
+	fl.SetReaderOptions(opts)
+	return fl
+}
+
+// SetReaderOptions changes the read options. They take effect
+// when the lexer starts reading its input. Reset clears SizeHint.
+func (ffl *FFLexer) SetReaderOptions(opts ReaderOptions) {
+	ffl.reader.bufferSize = opts.BufferSize
+	ffl.reader.sizeHint = opts.SizeHint
+}
+
+// NewFFLexerBytes returns a lexer that scans input in place.
+// Unlike NewFFLexer no read buffer is used and input is never copied,
+// so input must not be modified while the lexer is in use.
+func NewFFLexerBytes(input []byte) *FFLexer {
+	fl := &FFLexer{
+		Token:  FFTok_init,
+		Error:  FFErr_e_ok,
+		reader: newffReaderBytes(input),
+		Output: &Buffer{},
+	}
+	fl.reader.limits = &fl.Limits
+	fl.reader.syntax = &fl.Syntax
+	fl.reader.utf8 = &fl.UTF8Policy
+	fl.Output.Grow(64)
+	return fl
+}
+
+// LexerError adds the input position to errors that are neither
+// syntax nor type errors, such as read errors and exceeded Limits.
 type LexerError struct {
-	offset int
-	line   int
-	char   int
-	err    error
+	Offset int64
+	Line   int
+	Column int
+	// Path is the JSON path of the value being decoded, as in SyntaxError.
+	Path string
+	Err  error
+}
+
+// UnknownFieldError is returned by generated decoders when
+// DisallowUnknownFields is set and an object key matches no field.
+type UnknownFieldError struct {
+	// Field is the unescaped object key.
+	Field string
+	// Offset is the input offset where the key starts.
+	Offset int64
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("ffjson: unknown field %q at offset %d", e.Field, e.Offset)
 }
 
 // Reset the Lexer and add new input.
@@ -133,27 +238,90 @@ func (ffl *FFLexer) Reset(input io.Reader) {
 	ffl.BigError = nil
 	ffl.reader.Reset(input)
 	ffl.lastCurrentChar = 0
+	ffl.depth = 0
+	ffl.elements = ffl.elements[:0]
+	ffl.path = ffl.path[:0]
+	ffl.frames = ffl.frames[:0]
+	ffl.pending = false
+	ffl.json5State.reset()
 	ffl.Output.Reset()
 }
 
+// ResetBytes resets the Lexer to scan input in place.
+func (ffl *FFLexer) ResetBytes(input []byte) {
+	ffl.Token = FFTok_init
+	ffl.Error = FFErr_e_ok
+	ffl.BigError = nil
+	ffl.reader.ResetBytes(input)
+	ffl.lastCurrentChar = 0
+	ffl.depth = 0
+	ffl.elements = ffl.elements[:0]
+	ffl.path = ffl.path[:0]
+	ffl.frames = ffl.frames[:0]
+	ffl.pending = false
+	ffl.json5State.reset()
+	ffl.Output.Reset()
+}
+
+// PeekByte skips whitespace and returns the next byte of input
+// without consuming it. It returns io.EOF at the end of input.
+func (ffl *FFLexer) PeekByte() (byte, error) {
+	if ffl.json5() {
+		return ffl.peekJSON5()
+	}
+	c, err := ffl.reader.ReadByteNoWS()
+	if err != nil {
+		return 0, err
+	}
+	ffl.reader.UnreadByte()
+	return c, nil
+}
+
+// InputOffset returns the number of input bytes consumed so far.
+func (ffl *FFLexer) InputOffset() int64 {
+	return ffl.reader.InputOffset()
+}
+
+// TokenOffset returns the input offset where the last scanned token starts.
+func (ffl *FFLexer) TokenOffset() int64 {
+	return ffl.tokenOffset
+}
+
+// Buffered returns the input that has been read but not consumed.
+// The slice is only valid until the next call to the lexer.
+func (ffl *FFLexer) Buffered() []byte {
+	return ffl.reader.Buffered()
+}
+
 func (ffl *FFLexer) Release() {
 	ffl.reader.Release()
 }
 
 func (le *LexerError) Error() string {
-	return fmt.Sprintf(`ffjson error: (%T)%s offset=%d line=%d char=%d`,
-		le.err, le.err.Error(),
-		le.offset, le.line, le.char)
+	return fmt.Sprintf(`ffjson error: (%T)%s offset=%d line=%d char=%d path=%s`,
+		le.Err, le.Err.Error(),
+		le.Offset, le.Line, le.Column, le.Path)
 }
 
+func (le *LexerError) Unwrap() error {
+	return le.Err
+}
+
+// WrapErr adds the current input position and path to err.
+// Errors that already carry a position are returned unchanged,
+// so errors from nested generated decoders keep the innermost one.
 func (ffl *FFLexer) WrapErr(err error) error {
-	line, char := ffl.reader.PosWithLine()
-	// TOOD: calcualte lines/characters based on offset
+	switch err.(type) {
+	case *LexerError, *SyntaxError, *UnmarshalTypeError:
+		return err
+	}
+	offset, line, column := ffl.position()
 	return &LexerError{
-		offset: ffl.reader.Pos(),
-		line:   line,
-		char:   char,
-		err:    err,
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		Path:   ffl.Path(),
+		Err:    err,
 	}
 }
 
@@ -191,7 +359,7 @@ func (ffl *FFLexer) unreadByte() {
 }
 
 func (ffl *FFLexer) wantBytes(want []byte, iftrue FFTok) FFTok {
-	startPos := ffl.reader.Pos()
+	ffl.reader.Mark()
 	for _, b := range want {
 		c, err := ffl.readByte()
 
@@ -208,8 +376,7 @@ func (ffl *FFLexer) wantBytes(want []byte, iftrue FFTok) FFTok {
 		}
 	}
 
-	endPos := ffl.reader.Pos()
-	ffl.Output.Write(ffl.reader.Slice(startPos, endPos))
+	ffl.Output.Write(ffl.reader.Marked())
 	return iftrue
 }
 
@@ -222,8 +389,13 @@ func (ffl *FFLexer) lexComment() FFTok {
 	if c == '/' {
 		// a // comment, scan until line ends.
 		for {
-			c, err := ffl.readByte()
+			c, err := ffl.reader.ReadByte()
+			if err == io.EOF {
+				return FFTok_comment
+			}
 			if err != nil {
+				ffl.Error = FFErr_io
+				ffl.BigError = err
 				return FFTok_error
 			}
 
@@ -250,8 +422,8 @@ func (ffl *FFLexer) lexComment() FFTok {
 					return FFTok_comment
 				}
 
-				ffl.Error = FFErr_incomplete_comment
-				return FFTok_error
+				// a '*' inside the comment, or the first of "**/".
+				ffl.unreadByte()
 			}
 		}
 	} else {
@@ -260,13 +432,13 @@ func (ffl *FFLexer) lexComment() FFTok {
 	}
 }
 
-func (ffl *FFLexer) lexString() FFTok {
+func (ffl *FFLexer) lexString(quote byte) FFTok {
 	if ffl.captureAll {
 		ffl.buf.Reset()
-		err := ffl.reader.SliceString(&ffl.buf)
+		err := ffl.reader.sliceString(&ffl.buf, quote)
 
 		if err != nil {
-			ffl.BigError = err
+			ffl.stringErr(err)
 			return FFTok_error
 		}
 
@@ -274,10 +446,10 @@ func (ffl *FFLexer) lexString() FFTok {
 
 		return FFTok_string
 	} else {
-		err := ffl.reader.SliceString(ffl.Output)
+		err := ffl.reader.sliceString(ffl.Output, quote)
 
 		if err != nil {
-			ffl.BigError = err
+			ffl.stringErr(err)
 			return FFTok_error
 		}
 
@@ -285,19 +457,50 @@ func (ffl *FFLexer) lexString() FFTok {
 	}
 }
 
+func (ffl *FFLexer) stringErr(err error) {
+	ffl.BigError = err
+	var ue *UTF8Error
+	if errors.As(err, &ue) {
+		ffl.Error = FFErr_string_invalid_utf8
+	}
+}
+
+// readNumByte is readByte for lexNumber, where the input may end
+// right after a number. It returns -1 at the end of input.
+func (ffl *FFLexer) readNumByte() (int, error) {
+	c, err := ffl.reader.ReadByte()
+	if err == io.EOF {
+		return -1, nil
+	}
+	if err != nil {
+		ffl.Error = FFErr_io
+		ffl.BigError = err
+		return 0, err
+	}
+
+	return int(c), nil
+}
+
+// unreadNumByte gives back c, the byte read after a number.
+func (ffl *FFLexer) unreadNumByte(c int) {
+	if c >= 0 {
+		ffl.unreadByte()
+	}
+}
+
 func (ffl *FFLexer) lexNumber() FFTok {
 	var numRead int = 0
 	tok := FFTok_integer
-	startPos := ffl.reader.Pos()
+	ffl.reader.Mark()
 
-	c, err := ffl.readByte()
+	c, err := ffl.readNumByte()
 	if err != nil {
 		return FFTok_error
 	}
 
 	/* optional leading minus */
 	if c == '-' {
-		c, err = ffl.readByte()
+		c, err = ffl.readNumByte()
 		if err != nil {
 			return FFTok_error
 		}
@@ -305,40 +508,40 @@ func (ffl *FFLexer) lexNumber() FFTok {
 
 	/* a single zero, or a series of integers */
 	if c == '0' {
-		c, err = ffl.readByte()
+		c, err = ffl.readNumByte()
 		if err != nil {
 			return FFTok_error
 		}
 	} else if c >= '1' && c <= '9' {
 		for c >= '0' && c <= '9' {
-			c, err = ffl.readByte()
+			c, err = ffl.readNumByte()
 			if err != nil {
 				return FFTok_error
 			}
 		}
 	} else {
-		ffl.unreadByte()
+		ffl.unreadNumByte(c)
 		ffl.Error = FFErr_missing_integer_after_minus
 		return FFTok_error
 	}
 
 	if c == '.' {
 		numRead = 0
-		c, err = ffl.readByte()
+		c, err = ffl.readNumByte()
 		if err != nil {
 			return FFTok_error
 		}
 
 		for c >= '0' && c <= '9' {
 			numRead++
-			c, err = ffl.readByte()
+			c, err = ffl.readNumByte()
 			if err != nil {
 				return FFTok_error
 			}
 		}
 
 		if numRead == 0 {
-			ffl.unreadByte()
+			ffl.unreadNumByte(c)
 
 			ffl.Error = FFErr_missing_integer_after_decimal
 			return FFTok_error
@@ -350,14 +553,14 @@ func (ffl *FFLexer) lexNumber() FFTok {
 	/* optional exponent (indicates this is floating point) */
 	if c == 'e' || c == 'E' {
 		numRead = 0
-		c, err = ffl.readByte()
+		c, err = ffl.readNumByte()
 		if err != nil {
 			return FFTok_error
 		}
 
 		/* optional sign */
 		if c == '+' || c == '-' {
-			c, err = ffl.readByte()
+			c, err = ffl.readNumByte()
 			if err != nil {
 				return FFTok_error
 			}
@@ -365,7 +568,7 @@ func (ffl *FFLexer) lexNumber() FFTok {
 
 		for c >= '0' && c <= '9' {
 			numRead++
-			c, err = ffl.readByte()
+			c, err = ffl.readNumByte()
 			if err != nil {
 				return FFTok_error
 			}
@@ -379,13 +582,26 @@ func (ffl *FFLexer) lexNumber() FFTok {
 		tok = FFTok_double
 	}
 
-	ffl.unreadByte()
+	/* a number must end at a delimiter, so "01" and "1x" are errors */
+	if ffl.strict() && c >= 0 && !isNumberEnd(byte(c)) {
+		ffl.Error = FFErr_invalid_char
+		return FFTok_error
+	}
 
-	endPos := ffl.reader.Pos()
-	ffl.Output.Write(ffl.reader.Slice(startPos, endPos))
+	ffl.unreadNumByte(c)
+
+	ffl.Output.Write(ffl.reader.Marked())
 	return tok
 }
 
+func isNumberEnd(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', ',', ':', ']', '}':
+		return true
+	}
+	return false
+}
+
 var true_bytes = []byte{'r', 'u', 'e'}
 var false_bytes = []byte{'a', 'l', 's', 'e'}
 var null_bytes = []byte{'u', 'l', 'l'}
@@ -396,16 +612,43 @@ func (ffl *FFLexer) Scan() FFTok {
 		ffl.Output.Reset()
 	}
 	ffl.Token = FFTok_init
+	ffl.reader.Unmark()
+
+	if ffl.json5() {
+		err := ffl.skipJSON5()
+		if ffl.json5State.comma {
+			ffl.json5State.comma = false
+			ffl.tokenOffset = ffl.json5State.commaOffset
+			tok = FFTok_comma
+			if ffl.captureAll {
+				ffl.Output.WriteByte(',')
+			}
+			goto lexed
+		}
+		if err == errJSON5Comment {
+			goto lexed
+		}
+	}
 
 	for {
 		c, err := ffl.scanReadByte()
 		if err != nil {
 			if err == io.EOF {
+				if ffl.Limits.MaxInputSize > 0 {
+					return ffl.checkLimits(FFTok_eof)
+				}
 				return FFTok_eof
 			} else {
 				return FFTok_error
 			}
 		}
+		ffl.tokenOffset = ffl.reader.InputOffset() - 1
+
+		if ffl.json5() && ffl.json5State.wantKey() && isIdentStart(c) {
+			ffl.unreadByte()
+			tok = ffl.lexIdentifier()
+			goto lexed
+		}
 
 		switch c {
 		case '{':
@@ -444,7 +687,14 @@ func (ffl *FFLexer) Scan() FFTok {
 				ffl.Output.WriteByte(':')
 			}
 			goto lexed
-		case '\t', '\n', '\v', '\f', '\r', ' ':
+		case '\v', '\f':
+			if ffl.strict() {
+				tok = FFTok_error
+				ffl.Error = FFErr_invalid_char
+				goto lexed
+			}
+			fallthrough
+		case '\t', '\n', '\r', ' ':
 			if ffl.captureAll {
 				ffl.Output.WriteByte(c)
 			}
@@ -461,7 +711,7 @@ func (ffl *FFLexer) Scan() FFTok {
 			tok = ffl.wantBytes(null_bytes, FFTok_null)
 			goto lexed
 		case '"':
-			tok = ffl.lexString()
+			tok = ffl.lexString('"')
 			goto lexed
 		case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			ffl.unreadByte()
@@ -472,9 +722,31 @@ func (ffl *FFLexer) Scan() FFTok {
 				return FFTok_error
 			}
 
-			tok = ffl.lexNumber()
+			if ffl.json5() {
+				tok = ffl.lexJSON5Number()
+			} else {
+				tok = ffl.lexNumber()
+			}
+			goto lexed
+		case '\'', '+', '.', 'I', 'N':
+			if !ffl.json5() {
+				tok = FFTok_error
+				ffl.Error = FFErr_invalid_char
+				goto lexed
+			}
+			if c == '\'' {
+				tok = ffl.lexString('\'')
+			} else {
+				ffl.unreadByte()
+				tok = ffl.lexJSON5Number()
+			}
 			goto lexed
 		case '/':
+			if ffl.strict() {
+				tok = FFTok_error
+				ffl.Error = FFErr_unallowed_comment
+				goto lexed
+			}
 			err := ffl.reader.LoadMore()
 			if err != nil {
 				ffl.Error = FFErr_io
@@ -492,6 +764,10 @@ func (ffl *FFLexer) Scan() FFTok {
 	}
 
 lexed:
+	tok = ffl.checkLimits(tok)
+	if ffl.json5() {
+		ffl.json5State.track(tok)
+	}
 	ffl.Token = tok
 	return tok
 }
@@ -513,30 +789,24 @@ func (ffl *FFLexer) scanField(start FFTok, capture bool) ([]byte, error) {
 				}
 			}
 
-			depth := 1
+			// The value ends when the frame pushed here is popped.
+			// step checks the tokens in between are valid JSON.
+			depth := len(ffl.frames)
+			ffl.frames = append(ffl.frames, frame{object: start == FFTok_left_bracket})
 			if capture {
 				ffl.captureAll = true
 			}
-			// TODO: work.
-		scanloop:
-			for {
+			for len(ffl.frames) > depth {
 				tok := ffl.Scan()
-				//fmt.Printf("capture-token: %v end: %v depth: %v\n", tok, end, depth)
 				switch tok {
 				case FFTok_eof:
-					return nil, errors.New("ffjson: unexpected EOF")
+					return nil, ffl.UnexpectedToken(end, tok)
 				case FFTok_error:
-					if ffl.BigError != nil {
-						return nil, ffl.BigError
-					}
-					return nil, ffl.Error.ToError()
-				case end:
-					depth--
-					if depth == 0 {
-						break scanloop
-					}
-				case start:
-					depth++
+					return nil, ffl.ScanError()
+				}
+				err := ffl.step(tok)
+				if err != nil {
+					return nil, err
 				}
 			}
 
@@ -572,7 +842,7 @@ func (ffl *FFLexer) scanField(start FFTok, capture bool) ([]byte, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("ffjson: invalid capture type: %v", start)
+	return nil, ffl.UnexpectedToken(FFTok_init, start)
 }
 
 // Captures an entire field value, including recursive objects,
@@ -684,6 +954,7 @@ func (tok FFTok) String() string {
  * cVHC - valid hex char
  * cNFP - needs further processing (from a string scanning perspective)
  * cNUC - needs utf8 checking when enabled (from a string scanning perspective)
+ * cSQ  - single quote, which ends single-quoted strings
  */
 
 const (
@@ -692,6 +963,7 @@ const (
 	cVHC int8 = 0x04
 	cNFP int8 = 0x08
 	cNUC int8 = 0x10
+	cSQ  int8 = 0x20
 )
 
 var byteLookupTable [256]int8 = [256]int8{
@@ -734,7 +1006,7 @@ var byteLookupTable [256]int8 = [256]int8{
 	0,                  /* 36 */
 	0,                  /* 37 */
 	0,                  /* 38 */
-	0,                  /* 39 */
+	cSQ,                /* 39 */
 	0,                  /* 40 */
 	0,                  /* 41 */
 	0,                  /* 42 */
@@ -20,8 +20,11 @@ package v1
 import (
 	"bytes"
 	"errors"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func scanAll(ffl *FFLexer) []FFTok {
@@ -78,7 +81,7 @@ func scanToTokCount(ffl *FFLexer, targetTok FFTok) (int, error) {
 }
 
 func TestBasicLexing(t *testing.T) {
-	ffl := NewFFLexer([]byte(`{}`))
+	ffl := NewFFLexerBytes([]byte(`{}`))
 	toks := scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -88,7 +91,7 @@ func TestBasicLexing(t *testing.T) {
 }
 
 func TestHelloWorld(t *testing.T) {
-	ffl := NewFFLexer([]byte(`{"hello":"world"}`))
+	ffl := NewFFLexerBytes([]byte(`{"hello":"world"}`))
 	toks := scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -99,7 +102,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": 1}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": 1}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -110,7 +113,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": 1.0}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": 1.0}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -121,7 +124,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": 1e2}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": 1e2}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -132,7 +135,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": {}}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": {}}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -144,7 +147,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": {"blah": null}}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": {"blah": null}}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -159,7 +162,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": /* comment */ 0}`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": /* comment */ 0}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -171,7 +174,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"hello": / comment`))
+	ffl = NewFFLexerBytes([]byte(`{"hello": / comment`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -180,7 +183,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_error,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"陫ʋsş\")珷\u003cºɖgȏ哙ȍ":"2ħ籦ö嗏ʑ\u003e季"}`))
+	ffl = NewFFLexerBytes([]byte(`{"陫ʋsş\")珷\u003cºɖgȏ哙ȍ":"2ħ籦ö嗏ʑ\u003e季"}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -191,7 +194,7 @@ func TestHelloWorld(t *testing.T) {
 		FFTok_eof,
 	}, toks)
 
-	ffl = NewFFLexer([]byte(`{"X":{"陫ʋsş\")珷\u003cºɖgȏ哙ȍ":"2ħ籦ö嗏ʑ\u003e季"}}`))
+	ffl = NewFFLexerBytes([]byte(`{"X":{"陫ʋsş\")珷\u003cºɖgȏ哙ȍ":"2ħ籦ö嗏ʑ\u003e季"}}`))
 	toks = scanAll(ffl)
 	assertTokensEqual(t, []FFTok{
 		FFTok_left_bracket,
@@ -208,7 +211,7 @@ func TestHelloWorld(t *testing.T) {
 }
 
 func tDouble(t *testing.T, input string, target float64) {
-	ffl := NewFFLexer([]byte(input))
+	ffl := NewFFLexerBytes([]byte(input))
 	err := scanToTok(ffl, FFTok_double)
 	if err != nil {
 		t.Fatalf("scanToTok failed, couldnt find double: %v input: %v", err, input)
@@ -239,7 +242,7 @@ func TestDouble(t *testing.T) {
 }
 
 func tInt(t *testing.T, input string, target int64) {
-	ffl := NewFFLexer([]byte(input))
+	ffl := NewFFLexerBytes([]byte(input))
 	err := scanToTok(ffl, FFTok_integer)
 	if err != nil {
 		t.Fatalf("scanToTok failed, couldnt find int: %v input: %v", err, input)
@@ -269,7 +272,7 @@ func TestInt(t *testing.T) {
 }
 
 func tError(t *testing.T, input string, targetCount int, targetError FFErr) {
-	ffl := NewFFLexer([]byte(input))
+	ffl := NewFFLexerBytes([]byte(input))
 	count, err := scanToTokCount(ffl, FFTok_error)
 	if err != nil {
 		t.Fatalf("scanToTok failed, couldnt find error token: %v input: %v", err, input)
@@ -304,7 +307,7 @@ func TestInvalid(t *testing.T) {
 }
 
 func TestCapture(t *testing.T) {
-	ffl := NewFFLexer([]byte(`{"hello": {"blah": [null, 1]}}`))
+	ffl := NewFFLexerBytes([]byte(`{"hello": {"blah": [null, 1]}}`))
 
 	err := scanToTok(ffl, FFTok_left_bracket)
 	if err != nil {
@@ -325,3 +328,251 @@ func TestCapture(t *testing.T) {
 		t.Fatalf("didnt capture subfield: buf: %v", string(buf))
 	}
 }
+
+func TestLexerBytesNoCopy(t *testing.T) {
+	input := []byte(`{"hello": "world"}`)
+	ffl := NewFFLexerBytes(input)
+	toks := scanAll(ffl)
+	assertTokensEqual(t, []FFTok{
+		FFTok_left_bracket,
+		FFTok_string,
+		FFTok_colon,
+		FFTok_string,
+		FFTok_right_bracket,
+		FFTok_eof,
+	}, toks)
+
+	ffl.Release()
+	if string(input) != `{"hello": "world"}` {
+		t.Fatalf("input was modified by Release: %q", input)
+	}
+
+	ffl = NewFFLexerBytes(input)
+	ffl.ResetBytes([]byte(`[1]`))
+	toks = scanAll(ffl)
+	assertTokensEqual(t, []FFTok{
+		FFTok_left_brace,
+		FFTok_integer,
+		FFTok_right_brace,
+		FFTok_eof,
+	}, toks)
+}
+
+func TestReaderRefill(t *testing.T) {
+	input := `{"hello": "wo\u00e9rld\n", "` + strings.Repeat("a", 150) + `": 12345678,` +
+		` "b\"b": true, "c": -1.5e3, "d": [null, false, ` + strings.Repeat("9", 300) + `]}`
+
+	var want []string
+	ffl := NewFFLexerBytes([]byte(input))
+	for {
+		tok := ffl.Scan()
+		want = append(want, tok.String()+ffl.Output.String())
+		if tok == FFTok_eof || tok == FFTok_error {
+			break
+		}
+	}
+
+	ffl = NewFFLexer(iotest.OneByteReader(strings.NewReader(input)))
+	defer ffl.Release()
+	for i := 0; ; i++ {
+		tok := ffl.Scan()
+		got := tok.String() + ffl.Output.String()
+		if got != want[i] {
+			t.Fatalf("token %d: expected %q, got %q", i, want[i], got)
+		}
+		if tok == FFTok_eof || tok == FFTok_error {
+			break
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		limits Limits
+		input  string
+		limit  string
+	}{
+		{Limits{MaxDepth: 2}, `[[1]]`, ""},
+		{Limits{MaxDepth: 2}, `[[[1]]]`, "MaxDepth"},
+		{Limits{MaxDepth: 2}, `{"a": [1], "b": {"c": [2]}}`, "MaxDepth"},
+		{Limits{MaxDepth: 1}, `[1] [2] [3]`, ""},
+		{Limits{}, strings.Repeat("[", DefaultMaxDepth) + strings.Repeat("]", DefaultMaxDepth), ""},
+		{Limits{}, strings.Repeat("[", DefaultMaxDepth+1), "MaxDepth"},
+		{Limits{MaxDepth: -1}, strings.Repeat("[", DefaultMaxDepth+1) + strings.Repeat("]", DefaultMaxDepth+1), ""},
+		{Limits{MaxElements: 3}, `[1, [4, 5, 6], 3]`, ""},
+		{Limits{MaxElements: 3}, `[1, 2, 3, 4]`, "MaxElements"},
+		{Limits{MaxElements: 2}, `{"a": 1, "b": [], "c": 3}`, "MaxElements"},
+		{Limits{MaxStringLength: 5}, `["hello", "aé"]`, ""},
+		{Limits{MaxStringLength: 5}, `["hello!"]`, "MaxStringLength"},
+		{Limits{MaxStringLength: 5}, `{"hello!": 1}`, "MaxStringLength"},
+		{Limits{MaxInputSize: 7}, `[1, 22]`, ""},
+		{Limits{MaxInputSize: 7}, `[1, 223]`, "MaxInputSize"},
+		{Limits{MaxInputSize: 7}, `[1, 2]    `, "MaxInputSize"},
+	}
+
+	for _, tc := range cases {
+		for _, oneByte := range []bool{false, true} {
+			var ffl *FFLexer
+			if oneByte {
+				ffl = NewFFLexer(iotest.OneByteReader(strings.NewReader(tc.input)))
+			} else {
+				ffl = NewFFLexerBytes([]byte(tc.input))
+			}
+			ffl.Limits = tc.limits
+
+			toks := scanAll(ffl)
+			last := toks[len(toks)-1]
+			var le *LimitError
+			if tc.limit == "" {
+				if last != FFTok_eof {
+					t.Errorf("%s (reader: %v): unexpected error: %v", tc.input, oneByte, ffl.BigError)
+				}
+			} else if last != FFTok_error || !errors.As(ffl.BigError, &le) {
+				t.Errorf("%s (reader: %v): expected LimitError, got %v %v", tc.input, oneByte, last, ffl.BigError)
+			} else if le.Limit != tc.limit {
+				t.Errorf("%s (reader: %v): expected %s, got %v", tc.input, oneByte, tc.limit, le)
+			}
+			ffl.Release()
+		}
+	}
+}
+
+func TestDecodeInterfaceDepth(t *testing.T) {
+	ffl := NewFFLexerBytes([]byte(strings.Repeat("[", 5000000)))
+	defer ffl.Release()
+
+	_, err := ffl.DecodeInterface(ffl.Scan())
+	var le *LimitError
+	if !errors.As(err, &le) || le.Limit != "MaxDepth" || le.Max != DefaultMaxDepth {
+		t.Fatalf("expected MaxDepth LimitError, got %v", err)
+	}
+}
+
+func TestLimitsBoundRead(t *testing.T) {
+	input := `"` + strings.Repeat("a", 1<<20)
+	r := strings.NewReader(input)
+	ffl := NewFFLexer(r)
+	defer ffl.Release()
+	ffl.Limits = Limits{MaxInputSize: 100}
+
+	if tok := ffl.Scan(); tok != FFTok_error {
+		t.Fatalf("expected error, got %v", tok)
+	}
+	if read := int64(len(input)) - int64(r.Len()); read > 101 {
+		t.Fatalf("read %d bytes past a limit of 100", read)
+	}
+}
+
+func TestErrorPositionAcrossRefills(t *testing.T) {
+	input := "[" + strings.Repeat("\n  1,", 300) + "\n  x]"
+	x := strings.IndexByte(input, 'x')
+
+	for _, oneByte := range []bool{false, true} {
+		var ffl *FFLexer
+		if oneByte {
+			ffl = NewFFLexer(iotest.OneByteReader(strings.NewReader(input)))
+		} else {
+			ffl = NewFFLexerBytes([]byte(input))
+		}
+
+		toks := scanAll(ffl)
+		if toks[len(toks)-1] != FFTok_error {
+			t.Fatalf("expected error, got %v", toks[len(toks)-1])
+		}
+		var se *SyntaxError
+		if !errors.As(ffl.ScanError(), &se) {
+			t.Fatalf("expected SyntaxError, got %v", ffl.ScanError())
+		}
+		if se.Offset != int64(x+1) || se.Line != 302 || se.Column != 3 {
+			t.Errorf("reader: %v: expected offset=%d line=302 column=3, got %v", oneByte, x+1, se)
+		}
+		ffl.Release()
+	}
+}
+
+func TestNumberAtEOF(t *testing.T) {
+	for _, input := range []string{`1`, `-12.5e3`, `[0`} {
+		ffl := NewFFLexerBytes([]byte(input))
+		toks := scanAll(ffl)
+		if toks[len(toks)-1] != FFTok_eof {
+			t.Errorf("%s: expected eof, got %v", input, toks)
+		}
+	}
+}
+
+func TestCommentWithStar(t *testing.T) {
+	ffl := NewFFLexerBytes([]byte(`[/* a * b **/ 1]`))
+	toks := scanAll(ffl)
+	assertTokensEqual(t, []FFTok{
+		FFTok_left_brace,
+		FFTok_comment,
+		FFTok_integer,
+		FFTok_right_brace,
+		FFTok_eof,
+	}, toks)
+}
+
+func TestStrict(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		err   FFErr
+	}{
+		{`[1, /* comment */ 2]`, FFErr_unallowed_comment},
+		{"[1,\v2]", FFErr_invalid_char},
+		{`[01]`, FFErr_invalid_char},
+		{`[1x]`, FFErr_invalid_char},
+	} {
+		ffl := NewFFLexerBytes([]byte(tc.input))
+		ffl.Syntax = SyntaxStrict
+		toks := scanAll(ffl)
+		if toks[len(toks)-1] != FFTok_error || ffl.Error != tc.err {
+			t.Errorf("%q: expected %v, got %v %v", tc.input, tc.err, toks, ffl.Error)
+		}
+	}
+
+	ffl := NewFFLexerBytes([]byte("[\"\xe2\x82\"]"))
+	ffl.Syntax = SyntaxStrict
+	toks := scanAll(ffl)
+	if toks[len(toks)-1] != FFTok_error {
+		t.Fatalf("invalid UTF-8 accepted: %v", toks)
+	}
+	var se *SyntaxError
+	// The offset is that of the first byte of the invalid sequence.
+	if !errors.As(ffl.ScanError(), &se) || se.Offset != 2 {
+		t.Errorf("expected SyntaxError at offset 2, got %v", ffl.ScanError())
+	}
+}
+
+func TestDuplicateKeysInterface(t *testing.T) {
+	input := `{"a": 1, "b": {"c": 2, "c": [3]}, "a": {"x": 4}}`
+	for _, tc := range []struct {
+		policy DuplicateKeyPolicy
+		a, c   interface{}
+	}{
+		{DuplicateLastWins, map[string]interface{}{"x": 4.0}, []interface{}{3.0}},
+		{DuplicateFirstWins, 1.0, 2.0},
+	} {
+		ffl := NewFFLexerBytes([]byte(input))
+		ffl.DuplicateKeys = tc.policy
+		v, err := ffl.DecodeInterface(ffl.Scan())
+		if err != nil {
+			t.Fatalf("policy %d: unexpected error: %v", tc.policy, err)
+		}
+		m := v.(map[string]interface{})
+		if !reflect.DeepEqual(m["a"], tc.a) || !reflect.DeepEqual(m["b"].(map[string]interface{})["c"], tc.c) {
+			t.Errorf("policy %d: unexpected value %v", tc.policy, m)
+		}
+	}
+
+	ffl := NewFFLexerBytes([]byte(input))
+	ffl.DuplicateKeys = DuplicateError
+	_, err := ffl.DecodeInterface(ffl.Scan())
+	var de *DuplicateKeyError
+	var le *LexerError
+	if !errors.As(err, &de) || de.Key != "c" || de.Offset != 23 {
+		t.Fatalf("expected duplicate key c at offset 23, got %v", err)
+	}
+	if !errors.As(err, &le) || le.Path != "b.c" {
+		t.Errorf("expected path b.c, got %v", err)
+	}
+}
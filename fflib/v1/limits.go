@@ -0,0 +1,131 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import "fmt"
+
+// DefaultMaxDepth is the nesting depth allowed when Limits.MaxDepth
+// is zero. It keeps deeply nested input from overflowing the stack of
+// the recursive decoders, such as DecodeInterface.
+const DefaultMaxDepth = 10000
+
+// Limits bounds the resources a lexer, and the generated decoders
+// using it, may spend on a single input. A zero field means no limit,
+// except for MaxDepth.
+type Limits struct {
+	// MaxInputSize is the number of input bytes that may be consumed.
+	// At most one byte more than this is read from the underlying reader.
+	MaxInputSize int64
+	// MaxDepth is the number of objects and arrays that may be nested.
+	// Zero means DefaultMaxDepth, and a negative value no limit.
+	MaxDepth int
+	// MaxStringLength is the length of a string or key after unescaping.
+	MaxStringLength int
+	// MaxElements is the number of elements of a single array, or
+	// members of a single object.
+	MaxElements int
+}
+
+// LimitError is returned when the input exceeds one of the Limits.
+type LimitError struct {
+	// Limit is the name of the Limits field that was exceeded.
+	Limit string
+	// Max is the value of that field.
+	Max int64
+	// Offset is the input offset where the limit was exceeded.
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ffjson: input exceeds %s of %d at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+func (ffl *FFLexer) limitError(limit string, max int64) FFTok {
+	ffl.Error = FFErr_io
+	ffl.BigError = &LimitError{Limit: limit, Max: max, Offset: ffl.tokenOffset}
+	return FFTok_error
+}
+
+// checkLimits tracks nesting and element counts for tok, which has
+// just been scanned, and fails if they exceed ffl.Limits.
+func (ffl *FFLexer) checkLimits(tok FFTok) FFTok {
+	l := &ffl.Limits
+	if l.MaxInputSize > 0 && ffl.reader.InputOffset() > l.MaxInputSize {
+		return ffl.limitError("MaxInputSize", l.MaxInputSize)
+	}
+
+	switch tok {
+	case FFTok_left_bracket, FFTok_left_brace:
+		ffl.depth++
+		max := l.MaxDepth
+		if max == 0 {
+			max = DefaultMaxDepth
+		}
+		if max > 0 && ffl.depth > max {
+			return ffl.limitError("MaxDepth", int64(max))
+		}
+		if l.MaxElements > 0 {
+			ffl.elements = append(ffl.elements, 1)
+		}
+	case FFTok_right_bracket, FFTok_right_brace:
+		if ffl.depth > 0 {
+			ffl.depth--
+		}
+		if n := len(ffl.elements); n > 0 {
+			ffl.elements = ffl.elements[:n-1]
+		}
+	case FFTok_comma:
+		if n := len(ffl.elements); n > 0 {
+			ffl.elements[n-1]++
+			if ffl.elements[n-1] > l.MaxElements {
+				return ffl.limitError("MaxElements", int64(l.MaxElements))
+			}
+		}
+	}
+	return tok
+}
+
+// checkString fails if more than MaxStringLength bytes have been
+// written to out since it held start bytes.
+func (r *ffReader) checkString(out DecodingBuffer, start int) error {
+	if r.limits == nil || r.limits.MaxStringLength <= 0 {
+		return nil
+	}
+	if out.Len()-start > r.limits.MaxStringLength {
+		return &LimitError{
+			Limit:  "MaxStringLength",
+			Max:    int64(r.limits.MaxStringLength),
+			Offset: r.InputOffset(),
+		}
+	}
+	return nil
+}
+
+// readLimit returns how many more bytes fill may read, or -1 if the
+// input size is not limited. It reads one byte past MaxInputSize, so
+// input that is exactly at the limit can be told apart from a longer one.
+func (r *ffReader) readLimit() int64 {
+	if r.limits == nil || r.limits.MaxInputSize <= 0 {
+		return -1
+	}
+	n := r.limits.MaxInputSize + 1 - (r.discarded + int64(r.tail))
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
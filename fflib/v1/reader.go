@@ -18,26 +18,76 @@
 package v1
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math/bits"
 	"sync"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf16"
+	"unicode/utf8"
 )
 
 const sliceStringMask = cIJC | cNFP
 
+const (
+	// minBufferSize and maxBufferSize bound the size of read buffers
+	// chosen from earlier inputs. Only buffers within them are pooled.
+	minBufferSize = 512
+	maxBufferSize = 64 * 1024
+
+	// maxSizeHint caps the buffer allocated up front for a SizeHint,
+	// which often comes from an untrusted Content-Length. Larger
+	// inputs grow the buffer as they are read.
+	maxSizeHint = 4 << 20
+)
+
 var bufferPool = sync.Pool{}
 
-func acquireBuffer() []byte {
+// inputSize is a moving average of the size of inputs read so far.
+// It sizes read buffers when neither a buffer size nor a size hint
+// is given, so typical payloads are read with few calls to Read.
+var inputSize atomic.Int64
+
+func observeInputSize(n int64) {
+	avg := inputSize.Load()
+	if avg == 0 {
+		avg = n
+	} else {
+		avg += (n - avg) / 8
+	}
+	inputSize.Store(avg)
+}
+
+// adaptiveBufferSize returns the power of two that fits an average
+// input, within minBufferSize and maxBufferSize.
+func adaptiveBufferSize() int {
+	size := inputSize.Load() + 1
+	if size < minBufferSize {
+		return minBufferSize
+	}
+	if size > maxBufferSize {
+		return maxBufferSize
+	}
+	return 1 << bits.Len64(uint64(size-1))
+}
+
+func acquireBuffer(size int) []byte {
 	v := bufferPool.Get()
-	if v == nil {
-		return make([]byte, 128)
+	if v != nil {
+		b := v.([]byte)
+		if cap(b) >= size {
+			return b[:cap(b)]
+		}
 	}
-	return v.([]byte)
+	return make([]byte, size)
 }
 
 func releaseBuffer(buffer []byte) {
+	if cap(buffer) < minBufferSize || cap(buffer) > maxBufferSize {
+		return
+	}
 	clear(buffer)
 	//nolint:staticcheck
 	bufferPool.Put(buffer[:0])
@@ -48,21 +98,62 @@ type ffReader struct {
 	reader io.Reader
 	head   int
 	tail   int
+	// discarded counts the input bytes dropped from the front of
+	// buffer by refills, so discarded+head is the input offset.
+	discarded int64
+	// lines counts the newlines among the discarded bytes, and
+	// lineStart is the input offset just after the last of them.
+	lines     int
+	lineStart int64
+	// mark is the start of the token being lexed, or -1.
+	// Bytes from mark onwards are kept when the buffer is refilled.
+	mark int
+	// borrowed is set when buffer is the caller's input slice,
+	// which must never be modified or handed to the pool.
+	borrowed bool
+	// limits points to the Limits of the lexer using the reader, if any.
+	limits *Limits
+	// syntax points to the Syntax of the lexer using the reader, if any.
+	syntax *Syntax
+	// utf8 points to the UTF8Policy of the lexer using the reader, if any.
+	utf8 *UTF8Policy
+	// bufferSize and sizeHint are set from ReaderOptions.
+	bufferSize int
+	sizeHint   int64
 }
 
+// newffReader creates a reader for input. The buffer is only
+// allocated by the first read, once its options are known.
 func newffReader(input io.Reader) *ffReader {
 	return &ffReader{
-		buffer: acquireBuffer(),
 		head:   0,
 		reader: input,
 		tail:   0,
+		mark:   -1,
+	}
+}
+
+// newffReaderBytes creates a reader that lexes directly over input.
+// The whole input is available up front, so LoadMore never reads
+// and nothing is copied.
+func newffReaderBytes(input []byte) *ffReader {
+	return &ffReader{
+		buffer:   input,
+		head:     0,
+		tail:     len(input),
+		mark:     -1,
+		borrowed: true,
 	}
 }
 
 func (r *ffReader) Release() {
-	releaseBuffer(r.buffer)
+	if !r.borrowed {
+		r.observe()
+		releaseBuffer(r.buffer)
+	}
 	r.buffer = nil
 	r.reader = nil
+	r.borrowed = false
 }
 
 func (r *ffReader) Slice(start, stop int) []byte {
@@ -73,53 +164,218 @@ func (r *ffReader) Pos() int {
 	return r.head
 }
 
+// InputOffset returns the number of input bytes consumed so far.
+func (r *ffReader) InputOffset() int64 {
+	return r.discarded + int64(r.head)
+}
+
+// Buffered returns the input that has been read but not yet consumed.
+func (r *ffReader) Buffered() []byte {
+	return r.buffer[r.head:r.tail]
+}
+
+// Mark remembers the current position as the start of a token,
+// so the token is kept in the buffer even if it spans a refill.
+func (r *ffReader) Mark() {
+	r.mark = r.head
+}
+
+// Marked returns the bytes read since the last call to Mark.
+func (r *ffReader) Marked() []byte {
+	return r.buffer[r.mark:r.head]
+}
+
+// Unmark forgets the current mark.
+func (r *ffReader) Unmark() {
+	r.mark = -1
+}
+
 // Reset the reader, and add new input.
+// The buffer is kept, but the size hint is cleared.
 func (r *ffReader) Reset(d io.Reader) {
+	if r.borrowed {
+		r.buffer = nil
+		r.borrowed = false
+	} else {
+		r.observe()
+	}
+	r.sizeHint = 0
 	r.head = 0
 	r.reader = d
 	r.tail = 0
+	r.mark = -1
+	r.discarded = 0
+	r.lines = 0
+	r.lineStart = 0
 }
 
-// Calculates the Position with line and line offset,
-// because this isn't counted for performance reasons,
-// it will iterate the buffer from the beginning, and should
-// only be used in error-paths.
+// ResetBytes resets the reader to lex directly over d.
+func (r *ffReader) ResetBytes(d []byte) {
+	if !r.borrowed {
+		r.observe()
+		releaseBuffer(r.buffer)
+	}
+	r.buffer = d
+	r.borrowed = true
+	r.head = 0
+	r.reader = nil
+	r.tail = len(d)
+	r.mark = -1
+	r.discarded = 0
+	r.lines = 0
+	r.lineStart = 0
+}
+
+// PosWithLine returns the line of the input position, and the number
+// of bytes consumed on that line. Lines are only counted for input
+// dropped by refills, so the rest of the buffer is scanned, and it
+// should only be used in error-paths.
 func (r *ffReader) PosWithLine() (int, int) {
-	currentLine := 1
-	currentChar := 0
-
-	for i := 0; i < r.head; i++ {
-		c := r.buffer[i]
-		currentChar++
-		if c == '\n' {
-			currentLine++
-			currentChar = 0
+	window := r.buffer[:r.head]
+	line := 1 + r.lines + bytes.Count(window, newline)
+	start := r.lineStart
+	if i := bytes.LastIndexByte(window, '\n'); i >= 0 {
+		start = r.discarded + int64(i) + 1
+	}
+	return line, int(r.InputOffset() - start)
+}
+
+var newline = []byte{'\n'}
+
+// initialSize returns the buffer size to start reading input with.
+// Readers that report the unread length, like bytes.Reader and
+// strings.Reader, are taken as a size hint.
+func (r *ffReader) initialSize() int {
+	hint := r.sizeHint
+	if hint > maxSizeHint {
+		hint = maxSizeHint
+	}
+	if l, ok := r.reader.(interface{ Len() int }); ok && hint <= 0 {
+		hint = int64(l.Len())
+	}
+	if hint > 0 {
+		// One byte more, so the end of input is seen without growing.
+		hint++
+		if r.limits != nil && r.limits.MaxInputSize > 0 && hint > r.limits.MaxInputSize+1 {
+			hint = r.limits.MaxInputSize + 1
+		}
+		if hint < int64(maxInt) {
+			return int(hint)
 		}
 	}
+	if r.bufferSize > 0 {
+		return r.bufferSize
+	}
+	return adaptiveBufferSize()
+}
 
-	return currentLine, currentChar
+const maxInt = int(^uint(0) >> 1)
+
+// observe records the size of the input read so far, if any.
+func (r *ffReader) observe() {
+	if n := r.discarded + int64(r.tail); r.reader != nil && n > 0 {
+		observeInputSize(n)
+	}
 }
 
-func (r *ffReader) LoadMore() error {
-	if r.head == r.tail {
-		r.head = 0
-		r.tail = 0
-	} else {
-		return nil
+// maxConsecutiveEmptyReads is the number of (0, nil) reads tolerated
+// before fill gives up with io.ErrNoProgress.
+const maxConsecutiveEmptyReads = 100
+
+// fill reads more input into the buffer. Everything from the mark,
+// or from head if nothing is marked, is moved to the front of the
+// buffer first, and the buffer is grown if that leaves no room.
+// It returns io.EOF if no more input is available.
+func (r *ffReader) fill() error {
+	if r.reader == nil {
+		return io.EOF
+	}
+
+	if r.tail == 0 && r.discarded == 0 {
+		if size := r.initialSize(); len(r.buffer) < size {
+			releaseBuffer(r.buffer)
+			r.buffer = acquireBuffer(size)
+		}
+	}
+
+	keep := r.head
+	if r.mark >= 0 && r.mark < keep {
+		keep = r.mark
+	}
+
+	if keep > 0 {
+		dropped := r.buffer[:keep]
+		if n := bytes.Count(dropped, newline); n > 0 {
+			r.lines += n
+			r.lineStart = r.discarded + int64(bytes.LastIndexByte(dropped, '\n')) + 1
+		}
+		r.tail = copy(r.buffer, r.buffer[keep:r.tail])
+		r.discarded += int64(keep)
+		r.head -= keep
+		if r.mark >= 0 {
+			r.mark -= keep
+		}
+	}
+
+	if r.tail == len(r.buffer) {
+		buf := make([]byte, 2*len(r.buffer))
+		copy(buf, r.buffer[:r.tail])
+		releaseBuffer(r.buffer)
+		r.buffer = buf
 	}
 
-	n, err := r.reader.Read(r.buffer[r.tail:])
-	if n == 0 && err != nil {
-		if err != io.EOF {
+	end := len(r.buffer)
+	if limit := r.readLimit(); limit == 0 {
+		return &LimitError{
+			Limit:  "MaxInputSize",
+			Max:    r.limits.MaxInputSize,
+			Offset: r.discarded + int64(r.tail),
+		}
+	} else if limit > 0 && limit < int64(end-r.tail) {
+		end = r.tail + int(limit)
+	}
+
+	for i := 0; i < maxConsecutiveEmptyReads; i++ {
+		n, err := r.reader.Read(r.buffer[r.tail:end])
+		r.tail += n
+		if n > 0 {
+			return nil
+		}
+		if err != nil {
 			return err
 		}
 	}
 
-	r.tail += n
+	return io.ErrNoProgress
+}
 
+// ensure tries to make at least n unread bytes available.
+// Fewer bytes are available only if the input ends first.
+func (r *ffReader) ensure(n int) error {
+	for r.tail-r.head < n {
+		err := r.fill()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (r *ffReader) LoadMore() error {
+	if r.head != r.tail {
+		return nil
+	}
+
+	err := r.fill()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
 func (r *ffReader) ReadByteNoWS() (byte, error) {
 	err := r.LoadMore()
 	if err != nil {
@@ -150,7 +406,7 @@ func (r *ffReader) ReadByteNoWS() (byte, error) {
 				return c, nil
 			}
 		*/
-		if whitespaceLookupTable[c] == false {
+		if whitespaceLookupTable[c] == false || (c == '\v' || c == '\f') && r.strict() {
 			r.head = j
 			return c, nil
 		}
@@ -163,13 +419,19 @@ func (r *ffReader) ReadByteNoWS() (byte, error) {
 			}
 
 			j = r.head
+			if j >= r.tail {
+				return 0, io.EOF
+			}
 		}
 	}
 }
 
 func (r *ffReader) ReadByte() (byte, error) {
 	if r.head >= r.tail {
-		return 0, io.EOF
+		err := r.fill()
+		if err != nil {
+			return 0, err
+		}
 	}
 
 	r.head++
@@ -199,7 +461,7 @@ func (r *ffReader) readU4(j int) (rune, error) {
 			continue
 		} else {
 			// TODO(pquerna): handle errors better. layering violation.
-			return -1, fmt.Errorf("lex_string_invalid_hex_char: %v %v", c, string(u4[:]))
+			return -1, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_hex_char: %v %v", c, string(u4[:]))}
 		}
 	}
 
@@ -226,6 +488,9 @@ func (r *ffReader) handleEscaped(c byte, j int, out DecodingBuffer) (int, error)
 		}
 
 		if utf16.IsSurrogate(ru) {
+			if j+6 > r.tail || r.buffer[j+4] != '\\' || r.buffer[j+5] != 'u' {
+				return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_unicode_surrogate: lone %U", ru)}
+			}
 			ru2, err := r.readU4(j + 6)
 			if err != nil {
 				return 0, err
@@ -237,7 +502,7 @@ func (r *ffReader) handleEscaped(c byte, j int, out DecodingBuffer) (int, error)
 			if rval != unicode.ReplacementChar {
 				out.WriteRune(rval)
 			} else {
-				return 0, fmt.Errorf("lex_string_invalid_unicode_surrogate: %v %v", ru, ru2)
+				return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_unicode_surrogate: %v %v", ru, ru2)}
 			}
 		} else {
 			out.Write(r.buffer[r.head : j-2])
@@ -247,7 +512,10 @@ func (r *ffReader) handleEscaped(c byte, j int, out DecodingBuffer) (int, error)
 		}
 		return j, nil
 	} else if byteLookupTable[c]&cVEC == 0 {
-		return 0, fmt.Errorf("lex_string_invalid_escaped_char: %v", c)
+		if r.json5() {
+			return r.handleJSON5Escape(c, j, out)
+		}
+		return 0, &SyntaxError{Err: fmt.Errorf("lex_string_invalid_escaped_char: %v", c)}
 	} else {
 		out.Write(r.buffer[r.head : j-2])
 		r.head = j
@@ -277,50 +545,94 @@ func (r *ffReader) handleEscaped(c byte, j int, out DecodingBuffer) (int, error)
 }
 
 func (r *ffReader) SliceString(out DecodingBuffer) error {
-	// TODO(pquerna): string_with_escapes? de-escape here?
+	return r.sliceString(out, '"')
+}
+
+// sliceString reads a string up to the closing quote, which is '"'
+// unless single-quoted strings are allowed in SyntaxJSON5.
+func (r *ffReader) sliceString(out DecodingBuffer, quote byte) error {
 	j := r.head
+	start := out.Len()
+
+	mask := sliceStringMask
+	policy := r.utf8Policy()
+	if policy == UTF8Replace || policy == UTF8Reject {
+		mask |= cNUC
+	}
+	if quote == '\'' {
+		mask |= cSQ
+	}
 
 	for {
 		if j >= r.tail {
-			return io.EOF
-		}
-
-		for {
-			if j >= r.tail {
-				out.Write(r.buffer[r.head:j])
-				r.head = j
-
-				err := r.LoadMore()
-				if err != nil {
-					return err
-				}
+			out.Write(r.buffer[r.head:j])
+			r.head = j
 
-				j = r.head
+			err := r.checkString(out, start)
+			if err != nil {
+				return err
 			}
 
-			c := r.buffer[j]
-			if c == '"' || byteLookupTable[c]&sliceStringMask != 0 {
-				out.Write(r.buffer[r.head:j])
-				j++
-				break
+			err = r.LoadMore()
+			if err != nil {
+				return err
 			}
 
-			j++
+			j = r.head
+			if j >= r.tail {
+				return io.EOF
+			}
 		}
 
-		r.head = j
+		c := r.buffer[j]
+		j++
+		if byteLookupTable[c]&mask == 0 {
+			continue
+		}
 
-		return nil
-		/*else if c == '\\' { // TODO handle escaped and utf16
-			var err error
-			j, err = r.handleEscaped(c, j, out)
+		if c >= utf8.RuneSelf {
+			// Make sure the whole sequence is in the buffer.
+			out.Write(r.buffer[r.head : j-1])
+			r.head = j - 1
+			err := r.ensure(utf8.UTFMax)
+			if err != nil {
+				return err
+			}
+			ru, size := utf8.DecodeRune(r.buffer[r.head:r.tail])
+			if ru == utf8.RuneError && size == 1 {
+				j, err = r.invalidUTF8(out, policy)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			j = r.head + size
+		} else if c == quote {
+			out.Write(r.buffer[r.head : j-1])
+			r.head = j
+			return r.checkString(out, start)
+		} else if c == '"' {
+			// in a single-quoted string.
+			continue
+		} else if c == '\\' {
+			// Make sure the whole escape sequence is in the buffer.
+			out.Write(r.buffer[r.head : j-1])
+			r.head = j - 1
+			err := r.ensure(12)
+			if err != nil {
+				return err
+			}
+			j, err = r.handleEscaped(c, r.head+1, out)
 			if err != nil {
 				return err
 			}
 		} else if byteLookupTable[c]&cIJC != 0 {
-			return fmt.Errorf("lex_string_invalid_json_char: %v", c)
-		}*/
-		continue
+			// JSON5 only disallows line terminators.
+			if r.json5() && c != '\n' && c != '\r' {
+				continue
+			}
+			return &SyntaxError{Err: fmt.Errorf("lex_string_invalid_json_char: %v", c)}
+		}
 	}
 }
 
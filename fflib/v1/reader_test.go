@@ -18,12 +18,14 @@
 package v1
 
 import (
+	"io"
+	"strings"
 	"testing"
 )
 
 func tsliceString(t *testing.T, expected string, enc string) {
 	var out Buffer
-	ffr := newffReader([]byte(enc + `"`))
+	ffr := newffReaderBytes([]byte(enc + `"`))
 	err := ffr.SliceString(&out)
 	if err != nil {
 		t.Fatalf("unexpect SliceString error: %v from %v", err, enc)
@@ -47,7 +49,7 @@ func TestUnicode(t *testing.T) {
 
 func TestBadUnicode(t *testing.T) {
 	var out Buffer
-	ffr := newffReader([]byte(`\u20--"`))
+	ffr := newffReaderBytes([]byte(`\u20--"`))
 	err := ffr.SliceString(&out)
 	if err == nil {
 		t.Fatalf("expected SliceString hex decode error")
@@ -56,7 +58,7 @@ func TestBadUnicode(t *testing.T) {
 
 func TestNonUnicodeEscape(t *testing.T) {
 	var out Buffer
-	ffr := newffReader([]byte(`\t\n\r"`))
+	ffr := newffReaderBytes([]byte(`\t\n\r"`))
 	err := ffr.SliceString(&out)
 	if err != nil {
 		t.Fatalf("unexpected SliceString error: %v", err)
@@ -65,9 +67,91 @@ func TestNonUnicodeEscape(t *testing.T) {
 
 func TestInvalidEscape(t *testing.T) {
 	var out Buffer
-	ffr := newffReader([]byte(`\x134"`))
+	ffr := newffReaderBytes([]byte(`\x134"`))
 	err := ffr.SliceString(&out)
 	if err == nil {
 		t.Fatalf("expected SliceString escape decode error")
 	}
 }
+
+// countingReader counts the calls to Read, and hides the Len
+// method of the underlying reader.
+type countingReader struct {
+	r     io.Reader
+	reads int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+func scanCounted(t *testing.T, input string, opts ReaderOptions) int {
+	cr := &countingReader{r: strings.NewReader(input)}
+	ffl := NewFFLexerOptions(cr, opts)
+	defer ffl.Release()
+	toks := scanAll(ffl)
+	if tok := toks[len(toks)-1]; tok != FFTok_eof {
+		t.Fatalf("expected eof, got %v: %v", tok, ffl.BigError)
+	}
+	return cr.reads
+}
+
+func TestReaderOptions(t *testing.T) {
+	input := "[" + strings.Repeat(`"abcdefgh", `, 1000) + "1]"
+
+	if reads := scanCounted(t, input, ReaderOptions{SizeHint: int64(len(input))}); reads != 2 {
+		t.Errorf("SizeHint: expected 2 reads, got %d", reads)
+	}
+	if reads := scanCounted(t, input, ReaderOptions{BufferSize: 2 * len(input)}); reads != 2 {
+		t.Errorf("BufferSize: expected 2 reads, got %d", reads)
+	}
+
+	// A wrong hint only costs extra reads.
+	if reads := scanCounted(t, input, ReaderOptions{SizeHint: 10}); reads < 2 {
+		t.Errorf("SizeHint: expected more reads, got %d", reads)
+	}
+
+	// A hint larger than MaxInputSize does not allocate past it.
+	ffl := NewFFLexerOptions(strings.NewReader(input), ReaderOptions{SizeHint: 1 << 40})
+	ffl.Limits.MaxInputSize = 100
+	ffl.Scan()
+	if n := len(ffl.reader.buffer); n > maxBufferSize {
+		t.Errorf("expected a pooled buffer, got %d bytes", n)
+	}
+	ffl.Release()
+
+	// Without limits the hint is still capped.
+	ffl = NewFFLexerOptions(strings.NewReader("{}"), ReaderOptions{SizeHint: 1 << 36})
+	ffl.Scan()
+	if n := len(ffl.reader.buffer); n > maxSizeHint+1 {
+		t.Errorf("expected at most %d bytes, got %d", maxSizeHint+1, n)
+	}
+	ffl.Release()
+}
+
+func TestReaderAdaptiveSize(t *testing.T) {
+	saved := inputSize.Load()
+	defer inputSize.Store(saved)
+
+	inputSize.Store(0)
+	if size := adaptiveBufferSize(); size != minBufferSize {
+		t.Fatalf("expected %d, got %d", minBufferSize, size)
+	}
+
+	input := "[" + strings.Repeat(" ", 3000) + "]"
+	for i := 0; i < 20; i++ {
+		scanCounted(t, input, ReaderOptions{})
+	}
+	if size := adaptiveBufferSize(); size != 4096 {
+		t.Fatalf("expected 4096, got %d", size)
+	}
+	if reads := scanCounted(t, input, ReaderOptions{}); reads != 2 {
+		t.Errorf("expected 2 reads, got %d", reads)
+	}
+
+	observeInputSize(1 << 30)
+	if size := adaptiveBufferSize(); size != maxBufferSize {
+		t.Fatalf("expected %d, got %d", maxBufferSize, size)
+	}
+}
@@ -0,0 +1,133 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"errors"
+	"io"
+)
+
+// DefaultFlushSize is the StreamBuffer threshold used when none is given.
+const DefaultFlushSize = 32 * 1024
+
+var errStreamRewind = errors.New("fflib.v1.StreamBuffer: cannot rewind data that has been flushed")
+
+// StreamBuffer is an EncodingBuffer that writes its content to an
+// io.Writer whenever more than a threshold has been buffered, so
+// encoding a large value only needs a bounded amount of memory.
+// Values handed to Encode are still buffered whole before flushing.
+//
+// Like bufio.Writer, the first write error is kept: later writes are
+// discarded and Flush returns the error. Call Flush when done to write
+// the rest of the data. Invalid UTF-8 under UTF8Reject is treated the
+// same way, so nothing after it reaches the io.Writer.
+type StreamBuffer struct {
+	Buffer
+	w         io.Writer
+	threshold int
+	err       error
+}
+
+// NewStreamBuffer returns a StreamBuffer writing to w. If threshold
+// is not positive, DefaultFlushSize is used.
+func NewStreamBuffer(w io.Writer, threshold int) *StreamBuffer {
+	if threshold <= 0 {
+		threshold = DefaultFlushSize
+	}
+	return &StreamBuffer{w: w, threshold: threshold}
+}
+
+func (b *StreamBuffer) check() {
+	if b.Len() >= b.threshold {
+		b.Flush()
+	}
+}
+
+// failed reports whether writes are being discarded after an error.
+func (b *StreamBuffer) failed() bool {
+	return b.err != nil || b.utf8Err != nil
+}
+
+func (b *StreamBuffer) Write(p []byte) (int, error) {
+	if b.failed() {
+		return len(p), b.err
+	}
+	n, _ := b.Buffer.Write(p)
+	b.check()
+	return n, b.err
+}
+
+func (b *StreamBuffer) WriteString(s string) (int, error) {
+	if b.failed() {
+		return len(s), b.err
+	}
+	n, _ := b.Buffer.WriteString(s)
+	b.check()
+	return n, b.err
+}
+
+func (b *StreamBuffer) WriteByte(c byte) error {
+	if b.failed() {
+		return b.err
+	}
+	b.Buffer.WriteByte(c)
+	b.check()
+	return b.err
+}
+
+func (b *StreamBuffer) Encode(v interface{}) error {
+	if b.failed() {
+		return b.err
+	}
+	err := b.Buffer.Encode(v)
+	if err != nil {
+		return err
+	}
+	b.check()
+	return b.err
+}
+
+// Rewind drops the last n bytes written, as long as they
+// have not been flushed yet.
+func (b *StreamBuffer) Rewind(n int) error {
+	if n > b.Len() {
+		return errStreamRewind
+	}
+	return b.Buffer.Rewind(n)
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+// After invalid UTF-8 under UTF8Reject it writes nothing and returns
+// the *UTF8Error.
+func (b *StreamBuffer) Flush() error {
+	if b.err != nil {
+		b.Buffer.Reset()
+		return b.err
+	}
+	if b.utf8Err != nil {
+		return b.utf8Err
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	_, b.err = b.Buffer.WriteTo(b.w)
+	if b.err != nil {
+		b.Buffer.Reset()
+	}
+	return b.err
+}
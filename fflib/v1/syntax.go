@@ -0,0 +1,103 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import "io"
+
+// Syntax selects the grammar a lexer accepts.
+type Syntax int
+
+const (
+	// SyntaxDefault accepts JSON as ffjson always has: /* */ and //
+	// comments are returned as FFTok_comment, vertical tabs and form
+	// feeds count as whitespace, and strings are not checked to be
+	// valid UTF-8. Generated decoders reject missing or extra commas,
+	// as in [1 2], [01], [1,] and {"a":1,}, but Decoder ignores
+	// anything after the top-level value.
+	SyntaxDefault Syntax = iota
+	// SyntaxStrict accepts exactly the JSON text of RFC 8259.
+	// Comments, other whitespace, numbers with leading zeros or not
+	// followed by a delimiter, lone surrogates and invalid UTF-8 in
+	// strings are syntax errors, and decoders call ExpectEOF after
+	// the top-level value.
+	SyntaxStrict
+	// SyntaxJSON5 accepts the extensions of JSON5 (https://json5.org),
+	// for hand-edited files such as configuration. Comments and
+	// trailing commas are skipped, so Scan never returns them, and
+	// unquoted keys and single-quoted strings are returned as
+	// FFTok_string. Hexadecimal numbers, and numbers with a '+' sign
+	// or a decimal point without digits on one side, are written to
+	// Output as JSON numbers. Infinity and NaN are returned as
+	// FFTok_double, which ParseFloat accepts.
+	SyntaxJSON5
+)
+
+func (ffl *FFLexer) strict() bool {
+	return ffl.Syntax == SyntaxStrict
+}
+
+func (r *ffReader) strict() bool {
+	return r.syntax != nil && *r.syntax == SyntaxStrict
+}
+
+func (ffl *FFLexer) json5() bool {
+	return ffl.Syntax == SyntaxJSON5
+}
+
+func (r *ffReader) json5() bool {
+	return r.syntax != nil && *r.syntax == SyntaxJSON5
+}
+
+// ExpectEOF returns a *SyntaxError unless only whitespace is left
+// in the input.
+func (ffl *FFLexer) ExpectEOF() error {
+	_, err := ffl.PeekByte()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return ffl.WrapErr(err)
+	}
+
+	tok := ffl.Scan()
+	if tok == FFTok_error {
+		return ffl.ScanError()
+	}
+	return ffl.UnexpectedToken(FFTok_eof, tok)
+}
+
+// Validate checks that data holds a single JSON value accepted by s,
+// optionally surrounded by whitespace.
+func Validate(data []byte, s Syntax) error {
+	ffl := NewFFLexerBytes(data)
+	ffl.Syntax = s
+
+	tok := ffl.Scan()
+	switch tok {
+	case FFTok_error:
+		return ffl.ScanError()
+	case FFTok_eof:
+		return ffl.UnexpectedToken(FFTok_init, tok)
+	}
+
+	err := ffl.SkipField(tok)
+	if err != nil {
+		return ffl.WrapErr(err)
+	}
+	return ffl.ExpectEOF()
+}
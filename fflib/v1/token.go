@@ -0,0 +1,456 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"fmt"
+	"io"
+)
+
+// Kind identifies a JSON token returned by the token API of FFLexer.
+type Kind int
+
+const (
+	KindInvalid Kind = iota
+	KindObjectStart
+	KindObjectEnd
+	KindArrayStart
+	KindArrayEnd
+	KindString
+	KindNumber
+	KindBool
+	KindNull
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInvalid:
+		return "invalid"
+	case KindObjectStart:
+		return "{"
+	case KindObjectEnd:
+		return "}"
+	case KindArrayStart:
+		return "["
+	case KindArrayEnd:
+		return "]"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindBool:
+		return "bool"
+	case KindNull:
+		return "null"
+	}
+	panic(fmt.Sprintf("unknown kind: %d", int(k)))
+}
+
+func kindOf(tok FFTok) Kind {
+	switch tok {
+	case FFTok_left_bracket:
+		return KindObjectStart
+	case FFTok_right_bracket:
+		return KindObjectEnd
+	case FFTok_left_brace:
+		return KindArrayStart
+	case FFTok_right_brace:
+		return KindArrayEnd
+	case FFTok_string:
+		return KindString
+	case FFTok_integer, FFTok_double:
+		return KindNumber
+	case FFTok_bool:
+		return KindBool
+	case FFTok_null:
+		return KindNull
+	}
+	return KindInvalid
+}
+
+// frameState is what may come next in an open object or array.
+type frameState uint8
+
+const (
+	expectFirst frameState = iota // after '{' or '['
+	expectKey                     // in an object, after ','
+	expectColon                   // after a key
+	expectValue                   // after ':', or in an array after ','
+	expectComma                   // after a value
+)
+
+// frame is an object or array opened through the token API.
+type frame struct {
+	object bool
+	state  frameState
+}
+
+// The token API reads JSON one token at a time, like
+// json.Decoder.Token. Commas and colons are checked and consumed
+// for the caller, so only values, keys and the start and end of
+// objects and arrays are returned. It can be used on its own, or
+// in a handwritten UnmarshalJSONFFLexer:
+//
+//	func (p *Point) UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error {
+//		l.Start(state)
+//		if kind, err := l.Next(); err != nil || kind != fflib.KindArrayStart {
+//			return ...
+//		}
+//		...
+//	}
+
+// Start must be called first by an UnmarshalJSONFFLexer using the
+// token API. Generated code passes FFParse_want_key after it has
+// scanned the opening token of the value; Start makes the next call
+// to Next return that token again, so the decoder reads the value
+// the same way whoever calls it.
+func (ffl *FFLexer) Start(state FFParseState) {
+	ffl.pending = state != FFParse_map_start
+}
+
+// separate consumes the ',' or ':' expected before the next token.
+func (ffl *FFLexer) separate() error {
+	n := len(ffl.frames)
+	if n == 0 || ffl.pending {
+		return nil
+	}
+	f := &ffl.frames[n-1]
+
+	var want FFTok
+	switch f.state {
+	case expectComma:
+		want = FFTok_comma
+	case expectColon:
+		want = FFTok_colon
+	default:
+		return nil
+	}
+
+	c, err := ffl.PeekByte()
+	if err == io.EOF {
+		return ffl.UnexpectedToken(want, FFTok_eof)
+	}
+	if err != nil {
+		return ffl.WrapErr(err)
+	}
+	if f.state == expectComma && (c == '}' && f.object || c == ']' && !f.object) {
+		return nil
+	}
+
+	tok := ffl.Scan()
+	if tok != want {
+		return ffl.UnexpectedToken(want, tok)
+	}
+	switch {
+	case want == FFTok_colon:
+		f.state = expectValue
+	case f.object:
+		f.state = expectKey
+	default:
+		f.state = expectValue
+	}
+	return nil
+}
+
+// advance checks that tok may come next and records it.
+func (ffl *FFLexer) advance(tok FFTok) error {
+	n := len(ffl.frames)
+	var f *frame
+	if n > 0 {
+		f = &ffl.frames[n-1]
+	}
+
+	switch tok {
+	case FFTok_error:
+		return ffl.ScanError()
+	case FFTok_right_bracket, FFTok_right_brace:
+		if f == nil || f.object != (tok == FFTok_right_bracket) ||
+			(f.state != expectFirst && f.state != expectComma) {
+			if f != nil && f.state == expectKey {
+				return ffl.UnexpectedToken(FFTok_string, tok)
+			}
+			return ffl.UnexpectedToken(FFTok_init, tok)
+		}
+		ffl.frames = ffl.frames[:n-1]
+		return nil
+	}
+
+	if f != nil && f.object && (f.state == expectFirst || f.state == expectKey) {
+		if tok != FFTok_string {
+			return ffl.UnexpectedToken(FFTok_string, tok)
+		}
+		f.state = expectColon
+		return nil
+	}
+
+	if kindOf(tok) == KindInvalid {
+		return ffl.UnexpectedToken(FFTok_init, tok)
+	}
+	if f != nil {
+		f.state = expectComma
+	}
+	if tok == FFTok_left_bracket || tok == FFTok_left_brace {
+		ffl.frames = append(ffl.frames, frame{object: tok == FFTok_left_bracket})
+	}
+	return nil
+}
+
+// step is advance for scanners that see every token, including
+// commas and colons.
+func (ffl *FFLexer) step(tok FFTok) error {
+	if tok == FFTok_comment {
+		return nil
+	}
+
+	n := len(ffl.frames)
+	if n > 0 {
+		f := &ffl.frames[n-1]
+		switch f.state {
+		case expectColon:
+			if tok != FFTok_colon {
+				return ffl.UnexpectedToken(FFTok_colon, tok)
+			}
+			f.state = expectValue
+			return nil
+		case expectComma:
+			if tok == FFTok_comma {
+				if f.object {
+					f.state = expectKey
+				} else {
+					f.state = expectValue
+				}
+				return nil
+			}
+			if tok != FFTok_right_bracket && tok != FFTok_right_brace {
+				return ffl.UnexpectedToken(FFTok_comma, tok)
+			}
+		}
+	}
+	return ffl.advance(tok)
+}
+
+// next reads the next token through the token API.
+func (ffl *FFLexer) next() (FFTok, error) {
+	err := ffl.separate()
+	if err != nil {
+		return FFTok_error, err
+	}
+
+	tok := ffl.Token
+	if ffl.pending {
+		ffl.pending = false
+	} else {
+		tok = ffl.Scan()
+	}
+	if tok == FFTok_eof && len(ffl.frames) == 0 {
+		return tok, io.EOF
+	}
+	return tok, ffl.advance(tok)
+}
+
+// Peek returns the kind of the next token without reading it.
+// It returns io.EOF at the end of input, outside of any object or array.
+func (ffl *FFLexer) Peek() (Kind, error) {
+	if ffl.pending {
+		return kindOf(ffl.Token), nil
+	}
+	err := ffl.separate()
+	if err != nil {
+		return KindInvalid, err
+	}
+
+	c, err := ffl.PeekByte()
+	if err == io.EOF && len(ffl.frames) == 0 {
+		return KindInvalid, io.EOF
+	}
+	if err == io.EOF {
+		return KindInvalid, ffl.UnexpectedToken(FFTok_init, FFTok_eof)
+	}
+	if err != nil {
+		return KindInvalid, ffl.WrapErr(err)
+	}
+
+	if ffl.json5() {
+		switch {
+		case c == '\'' || ffl.json5State.wantKey() && isIdentStart(c):
+			return KindString, nil
+		case c == '+' || c == '.' || c == 'I' || c == 'N':
+			return KindNumber, nil
+		}
+	}
+
+	switch c {
+	case '{':
+		return KindObjectStart, nil
+	case '}':
+		return KindObjectEnd, nil
+	case '[':
+		return KindArrayStart, nil
+	case ']':
+		return KindArrayEnd, nil
+	case '"':
+		return KindString, nil
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return KindNumber, nil
+	case 't', 'f':
+		return KindBool, nil
+	case 'n':
+		return KindNull, nil
+	}
+	return KindInvalid, ffl.UnexpectedToken(FFTok_init, ffl.Scan())
+}
+
+// More reports whether there is another element in the current
+// object or array, or another value at the top level.
+func (ffl *FFLexer) More() bool {
+	kind, err := ffl.Peek()
+	if err == io.EOF {
+		return false
+	}
+	// On errors the next read reports them.
+	return err != nil || (kind != KindObjectEnd && kind != KindArrayEnd)
+}
+
+// Next reads the next token and returns its kind. The text of
+// strings, numbers and booleans is then in Output, unescaped for
+// strings, until the next call to the lexer. It returns io.EOF at
+// the end of input, outside of any object or array.
+func (ffl *FFLexer) Next() (Kind, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return KindInvalid, err
+	}
+	return kindOf(tok), nil
+}
+
+// ReadString reads a string value or object key.
+func (ffl *FFLexer) ReadString() (string, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return "", err
+	}
+	if tok != FFTok_string {
+		return "", ffl.TypeErr(tok, "string", nil)
+	}
+	return ffl.Output.String(), nil
+}
+
+// ReadInt64 reads a number that must be an integer fitting an int64.
+func (ffl *FFLexer) ReadInt64() (int64, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return 0, err
+	}
+	if tok != FFTok_integer {
+		return 0, ffl.TypeErr(tok, "int64", nil)
+	}
+	v, err := ParseInt(ffl.Output.Bytes(), 10, 64)
+	if err != nil {
+		return 0, ffl.TypeErr(tok, "int64", err)
+	}
+	return v, nil
+}
+
+// ReadFloat64 reads a number.
+func (ffl *FFLexer) ReadFloat64() (float64, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return 0, err
+	}
+	if tok != FFTok_integer && tok != FFTok_double {
+		return 0, ffl.TypeErr(tok, "float64", nil)
+	}
+	v, err := ParseFloat(ffl.Output.Bytes(), 64)
+	if err != nil {
+		return 0, ffl.TypeErr(tok, "float64", err)
+	}
+	return v, nil
+}
+
+// ReadBool reads true or false.
+func (ffl *FFLexer) ReadBool() (bool, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return false, err
+	}
+	if tok != FFTok_bool {
+		return false, ffl.TypeErr(tok, "bool", nil)
+	}
+	return ffl.Output.Bytes()[0] == 't', nil
+}
+
+// Skip skips the next value, including everything inside it if it
+// is an object or array. If the next token is an object key, only
+// the key is skipped.
+func (ffl *FFLexer) Skip() error {
+	_, err := ffl.skip(false)
+	return err
+}
+
+// Capture reads the next value like Skip, and returns its JSON text,
+// which can be passed to json.Unmarshal. The slice is only valid
+// until the next call to the lexer.
+func (ffl *FFLexer) Capture() ([]byte, error) {
+	return ffl.skip(true)
+}
+
+func (ffl *FFLexer) skip(capture bool) ([]byte, error) {
+	tok, err := ffl.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok == FFTok_right_bracket || tok == FFTok_right_brace {
+		return nil, ffl.UnexpectedToken(FFTok_init, tok)
+	}
+	if tok == FFTok_left_bracket || tok == FFTok_left_brace {
+		// scanField reads up to the matching end itself.
+		ffl.frames = ffl.frames[:len(ffl.frames)-1]
+	}
+
+	b, err := ffl.scanField(tok, capture)
+	if err != nil {
+		return nil, ffl.WrapErr(err)
+	}
+	return b, nil
+}
+
+// Decode reads the next value with the UnmarshalJSONFFLexer of v,
+// so handwritten decoders can use generated ones for parts of the input.
+func (ffl *FFLexer) Decode(v interface {
+	UnmarshalJSONFFLexer(l *FFLexer, state FFParseState) error
+}) error {
+	err := ffl.separate()
+	if err != nil {
+		return err
+	}
+
+	if n := len(ffl.frames); n > 0 && !ffl.pending {
+		f := &ffl.frames[n-1]
+		if f.object && (f.state == expectFirst || f.state == expectKey) {
+			return ffl.UnexpectedToken(FFTok_string, ffl.Scan())
+		}
+		f.state = expectComma
+	}
+
+	state := FFParse_map_start
+	if ffl.pending {
+		ffl.pending = false
+		state = FFParse_want_key
+	}
+	return v.UnmarshalJSONFFLexer(ffl, state)
+}
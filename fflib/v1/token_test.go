@@ -0,0 +1,179 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokenLexers returns lexers for input in bytes and reader mode.
+func tokenLexers(input string) []*FFLexer {
+	return []*FFLexer{
+		NewFFLexerBytes([]byte(input)),
+		NewFFLexer(iotest.OneByteReader(strings.NewReader(input))),
+	}
+}
+
+func TestTokenNext(t *testing.T) {
+	input := ` {"a": [1, 2.5, "x\n", true, null], "b": {}} []`
+	want := []Kind{
+		KindObjectStart, KindString, KindArrayStart, KindNumber, KindNumber,
+		KindString, KindBool, KindNull, KindArrayEnd, KindString,
+		KindObjectStart, KindObjectEnd, KindObjectEnd, KindArrayStart, KindArrayEnd,
+	}
+	for _, ffl := range tokenLexers(input) {
+		for i, w := range want {
+			peek, err := ffl.Peek()
+			if err != nil || peek != w {
+				t.Fatalf("Peek %d: got %v, %v want %v", i, peek, err, w)
+			}
+			kind, err := ffl.Next()
+			if err != nil || kind != w {
+				t.Fatalf("Next %d: got %v, %v want %v", i, kind, err, w)
+			}
+		}
+		if _, err := ffl.Next(); err != io.EOF {
+			t.Fatalf("expected io.EOF, got %v", err)
+		}
+	}
+}
+
+func TestTokenRead(t *testing.T) {
+	input := `{"s": "aé", "i": -42, "f": 1e3, "b": false, "skip": {"x": [1, {"y": 2}]}, "c": [1, "a"]}`
+	for _, ffl := range tokenLexers(input) {
+		if kind, err := ffl.Next(); err != nil || kind != KindObjectStart {
+			t.Fatalf("Next: %v, %v", kind, err)
+		}
+		keys := []string{}
+		for ffl.More() {
+			key, err := ffl.ReadString()
+			if err != nil {
+				t.Fatalf("key: %v", err)
+			}
+			keys = append(keys, key)
+
+			switch key {
+			case "s":
+				v, err := ffl.ReadString()
+				if err != nil || v != "aé" {
+					t.Fatalf("ReadString: %q, %v", v, err)
+				}
+			case "i":
+				v, err := ffl.ReadInt64()
+				if err != nil || v != -42 {
+					t.Fatalf("ReadInt64: %v, %v", v, err)
+				}
+			case "f":
+				v, err := ffl.ReadFloat64()
+				if err != nil || v != 1000 {
+					t.Fatalf("ReadFloat64: %v, %v", v, err)
+				}
+			case "b":
+				v, err := ffl.ReadBool()
+				if err != nil || v {
+					t.Fatalf("ReadBool: %v, %v", v, err)
+				}
+			case "skip":
+				if err := ffl.Skip(); err != nil {
+					t.Fatalf("Skip: %v", err)
+				}
+			case "c":
+				v, err := ffl.Capture()
+				if err != nil || string(v) != `[1, "a"]` {
+					t.Fatalf("Capture: %s, %v", v, err)
+				}
+			}
+		}
+		if kind, err := ffl.Next(); err != nil || kind != KindObjectEnd {
+			t.Fatalf("Next: %v, %v", kind, err)
+		}
+		if strings.Join(keys, ",") != "s,i,f,b,skip,c" {
+			t.Fatalf("keys: %v", keys)
+		}
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected FFTok
+	}{
+		{`[1 2]`, FFTok_comma},
+		{`[1,]`, FFTok_init},
+		{`[,1]`, FFTok_init},
+		{`{"a" 1}`, FFTok_colon},
+		{`{1: 2}`, FFTok_string},
+		{`{"a": 1,}`, FFTok_string},
+		{`[1}`, FFTok_comma},
+		{`[1 `, FFTok_comma},
+		{`]`, FFTok_init},
+	}
+	for _, test := range tests {
+		for _, ffl := range tokenLexers(test.input) {
+			var err error
+			for err == nil {
+				_, err = ffl.Next()
+			}
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("%s: expected SyntaxError, got %v", test.input, err)
+			}
+			if se.Expected != test.expected {
+				t.Fatalf("%s: expected %v, got %v", test.input, test.expected, se.Expected)
+			}
+		}
+	}
+
+	ffl := NewFFLexerBytes([]byte(`["1", 1.5]`))
+	ffl.Next()
+	var te *UnmarshalTypeError
+	if _, err := ffl.ReadInt64(); !errors.As(err, &te) || te.Value != "string" {
+		t.Fatalf("expected UnmarshalTypeError, got %v", err)
+	}
+	if _, err := ffl.ReadInt64(); !errors.As(err, &te) || te.Value != "number 1.5" {
+		t.Fatalf("expected UnmarshalTypeError, got %v", err)
+	}
+}
+
+func TestTokenStart(t *testing.T) {
+	ffl := NewFFLexerBytes([]byte(`[1, 2]`))
+	if tok := ffl.Scan(); tok != FFTok_left_brace {
+		t.Fatalf("Scan: %v", tok)
+	}
+
+	// As called by generated code for a nested value.
+	ffl.Start(FFParse_want_key)
+	if kind, err := ffl.Peek(); err != nil || kind != KindArrayStart {
+		t.Fatalf("Peek: %v, %v", kind, err)
+	}
+	if kind, err := ffl.Next(); err != nil || kind != KindArrayStart {
+		t.Fatalf("Next: %v, %v", kind, err)
+	}
+	for _, want := range []int64{1, 2} {
+		if v, err := ffl.ReadInt64(); err != nil || v != want {
+			t.Fatalf("ReadInt64: %v, %v", v, err)
+		}
+	}
+	if kind, err := ffl.Next(); err != nil || kind != KindArrayEnd {
+		t.Fatalf("Next: %v, %v", kind, err)
+	}
+}
@@ -0,0 +1,169 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// UTF8Policy selects what happens to invalid UTF-8 in strings.
+type UTF8Policy int
+
+const (
+	// UTF8Default keeps the historical behaviour: WriteJson replaces
+	// invalid bytes with U+FFFD, and the lexer passes them through,
+	// unless its Syntax is SyntaxStrict, which rejects them.
+	UTF8Default UTF8Policy = iota
+	// UTF8Replace replaces each invalid byte with U+FFFD, like
+	// encoding/json does.
+	UTF8Replace
+	// UTF8Reject fails with a *UTF8Error giving the offset of the
+	// first invalid byte. A StreamBuffer writes nothing more to its
+	// io.Writer once the error is recorded.
+	UTF8Reject
+	// UTF8PassThrough copies invalid bytes unchanged. The output of
+	// WriteJson is then not valid JSON.
+	UTF8PassThrough
+)
+
+// UTF8Error is returned under UTF8Reject for invalid UTF-8.
+// The lexer returns it as the Err of a *SyntaxError.
+type UTF8Error struct {
+	// Offset is the input offset of the first invalid byte when
+	// decoding. When encoding it is the offset in the string.
+	Offset int64
+	// Byte is the first invalid byte.
+	Byte byte
+}
+
+func (e *UTF8Error) Error() string {
+	return fmt.Sprintf("ffjson: invalid UTF-8 byte %#x at offset %d", e.Byte, e.Offset)
+}
+
+type utf8Policer interface {
+	UTF8Policy() UTF8Policy
+}
+
+// UTF8PolicyOf returns the UTF8Policy of buf, which is UTF8Default
+// unless buf has a UTF8Policy method, such as Buffer.UTF8Policy.
+func UTF8PolicyOf(buf interface{}) UTF8Policy {
+	if p, ok := buf.(utf8Policer); ok {
+		return p.UTF8Policy()
+	}
+	return UTF8Default
+}
+
+type utf8Failer interface {
+	UTF8Err() error
+	failUTF8(err *UTF8Error)
+}
+
+// UTF8Err returns the *UTF8Error recorded by WriteJson for the first
+// invalid string written to buf under UTF8Reject, or nil. Generated
+// marshalers return it once they are done.
+func UTF8Err(buf interface{}) error {
+	if f, ok := buf.(utf8Failer); ok {
+		return f.UTF8Err()
+	}
+	return nil
+}
+
+// SetUTF8Policy selects what WriteJson does with invalid UTF-8 in
+// strings written to the buffer.
+func (b *Buffer) SetUTF8Policy(p UTF8Policy) {
+	b.utf8Policy = p
+}
+
+// UTF8Policy returns the policy set by SetUTF8Policy.
+func (b *Buffer) UTF8Policy() UTF8Policy {
+	return b.utf8Policy
+}
+
+// UTF8Err returns the first error recorded under UTF8Reject since
+// the buffer was last reset.
+func (b *Buffer) UTF8Err() error {
+	if b.utf8Err == nil {
+		return nil
+	}
+	return b.utf8Err
+}
+
+func (b *Buffer) failUTF8(err *UTF8Error) {
+	if b.utf8Err == nil {
+		b.utf8Err = err
+	}
+}
+
+// UTF8Policy returns the policy of the underlying buffer.
+func (b *IndentBuffer) UTF8Policy() UTF8Policy {
+	return UTF8PolicyOf(b.out)
+}
+
+// UTF8Err returns the error recorded by the underlying buffer.
+func (b *IndentBuffer) UTF8Err() error {
+	return UTF8Err(b.out)
+}
+
+func (b *IndentBuffer) failUTF8(err *UTF8Error) {
+	if f, ok := b.out.(utf8Failer); ok {
+		f.failUTF8(err)
+	}
+}
+
+// writeInvalidUTF8 writes the invalid byte s[i] of a string
+// according to the policy of buf.
+func writeInvalidUTF8(buf JsonStringWriter, s []byte, i int) {
+	switch UTF8PolicyOf(buf) {
+	case UTF8PassThrough:
+		buf.WriteByte(s[i])
+		return
+	case UTF8Reject:
+		if f, ok := buf.(utf8Failer); ok {
+			f.failUTF8(&UTF8Error{Offset: int64(i), Byte: s[i]})
+			return
+		}
+	}
+	buf.WriteString(`\ufffd`)
+}
+
+// utf8Policy is the policy of the lexer using the reader.
+func (r *ffReader) utf8Policy() UTF8Policy {
+	p := UTF8Default
+	if r.utf8 != nil {
+		p = *r.utf8
+	}
+	if p == UTF8Default && r.strict() {
+		p = UTF8Reject
+	}
+	return p
+}
+
+// invalidUTF8 handles an invalid UTF-8 byte at r.head in a string
+// being sliced into out, and returns the index to continue at.
+func (r *ffReader) invalidUTF8(out DecodingBuffer, policy UTF8Policy) (int, error) {
+	switch policy {
+	case UTF8Reject:
+		return 0, &SyntaxError{Err: &UTF8Error{Offset: r.InputOffset(), Byte: r.buffer[r.head]}}
+	case UTF8Replace:
+		out.WriteRune(utf8.RuneError)
+		r.head++
+		return r.head, nil
+	}
+	return r.head + 1, nil
+}
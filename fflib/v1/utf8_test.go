@@ -0,0 +1,175 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// malformedUTF8 are invalid sequences, with the number of bytes
+// utf8.DecodeRune rejects one at a time.
+var malformedUTF8 = []struct {
+	name string
+	seq  string
+	n    int
+}{
+	{"invalid byte", "\xff", 1},
+	{"lone continuation", "\x80", 1},
+	{"truncated", "\xe6\x97", 2},
+	{"overlong", "\xc0\xaf", 2},
+	{"surrogate", "\xed\xa0\x80", 3},
+	{"too big", "\xf4\xbf\xbf\xbf", 4},
+}
+
+func TestWriteJsonUTF8(t *testing.T) {
+	for _, tc := range malformedUTF8 {
+		s := "a" + tc.seq + "b"
+		replaced := `"a` + strings.Repeat(`\ufffd`, tc.n) + `b"`
+
+		for _, p := range []UTF8Policy{UTF8Default, UTF8Replace} {
+			var buf Buffer
+			buf.SetUTF8Policy(p)
+			WriteJsonString(&buf, s)
+			if buf.String() != replaced || buf.UTF8Err() != nil {
+				t.Errorf("%s, policy %d: expected %s, got %s %v", tc.name, p, replaced, buf.String(), buf.UTF8Err())
+			}
+		}
+
+		var buf Buffer
+		buf.SetUTF8Policy(UTF8PassThrough)
+		WriteJsonString(&buf, s)
+		if buf.String() != `"`+s+`"` {
+			t.Errorf("%s: expected bytes passed through, got %q", tc.name, buf.String())
+		}
+
+		buf.Reset()
+		buf.SetUTF8Policy(UTF8Reject)
+		WriteJsonString(&buf, "ok")
+		WriteJsonString(&buf, s)
+		var ue *UTF8Error
+		if !errors.As(buf.UTF8Err(), &ue) || ue.Offset != 1 || ue.Byte != tc.seq[0] {
+			t.Errorf("%s: expected error at offset 1, got %v", tc.name, buf.UTF8Err())
+		}
+		buf.Reset()
+		if buf.UTF8Err() != nil {
+			t.Errorf("%s: Reset kept %v", tc.name, buf.UTF8Err())
+		}
+	}
+
+	var buf Buffer
+	buf.SetUTF8Policy(UTF8Reject)
+	WriteJsonString(&buf, "hé 日本 \U0001f600")
+	if buf.UTF8Err() != nil {
+		t.Errorf("valid UTF-8 rejected: %v", buf.UTF8Err())
+	}
+
+	ib := NewIndentBuffer(&buf, "", "  ")
+	WriteJsonString(ib, "\xff")
+	if UTF8Err(ib) == nil {
+		t.Error("IndentBuffer ignored the policy of the underlying buffer")
+	}
+}
+
+func TestStreamBufferUTF8Reject(t *testing.T) {
+	var w bytes.Buffer
+	sb := NewStreamBuffer(&w, 1)
+	sb.SetUTF8Policy(UTF8Reject)
+	WriteJsonString(sb, "ok")
+	WriteJsonString(sb, "a\xffb")
+	sb.WriteString("more")
+
+	var ue *UTF8Error
+	if err := sb.Flush(); !errors.As(err, &ue) || ue.Offset != 1 {
+		t.Errorf("expected UTF8Error at offset 1, got %v", err)
+	}
+	if w.String() != `"ok""a` {
+		t.Errorf("expected output to stop at the invalid byte, got %q", w.String())
+	}
+}
+
+func utf8Lexers(input string, p UTF8Policy) []*FFLexer {
+	lexers := []*FFLexer{
+		NewFFLexerBytes([]byte(input)),
+		NewFFLexer(iotest.OneByteReader(strings.NewReader(input))),
+	}
+	for _, l := range lexers {
+		l.UTF8Policy = p
+	}
+	return lexers
+}
+
+func TestLexerUTF8(t *testing.T) {
+	for _, tc := range malformedUTF8 {
+		input := `"a` + tc.seq + `b"`
+
+		for _, p := range []UTF8Policy{UTF8Default, UTF8PassThrough} {
+			for _, l := range utf8Lexers(input, p) {
+				tok := l.Scan()
+				if tok != FFTok_string || l.Output.String() != "a"+tc.seq+"b" {
+					t.Errorf("%s, policy %d: expected bytes passed through, got %v %q", tc.name, p, tok, l.Output)
+				}
+			}
+		}
+
+		for _, l := range utf8Lexers(input, UTF8Replace) {
+			want := "a" + strings.Repeat("\ufffd", tc.n) + "b"
+			tok := l.Scan()
+			if tok != FFTok_string || l.Output.String() != want {
+				t.Errorf("%s: expected %q, got %v %q", tc.name, want, tok, l.Output)
+			}
+		}
+
+		for _, l := range utf8Lexers(input, UTF8Reject) {
+			if tok := l.Scan(); tok != FFTok_error {
+				t.Errorf("%s: expected error, got %v %q", tc.name, tok, l.Output)
+				continue
+			}
+			if l.Error != FFErr_string_invalid_utf8 {
+				t.Errorf("%s: unexpected error code %v", tc.name, l.Error)
+			}
+			var ue *UTF8Error
+			err := l.ScanError()
+			if !errors.As(err, &ue) || ue.Offset != 2 || ue.Byte != tc.seq[0] {
+				t.Errorf("%s: expected error at offset 2, got %v", tc.name, err)
+			}
+		}
+	}
+
+	// SyntaxStrict rejects unless another policy is chosen.
+	l := NewFFLexerBytes([]byte("\"\xff\""))
+	l.Syntax = SyntaxStrict
+	if tok := l.Scan(); tok != FFTok_error {
+		t.Errorf("strict: expected error, got %v", tok)
+	}
+	l = NewFFLexerBytes([]byte("\"\xff\""))
+	l.Syntax = SyntaxStrict
+	l.UTF8Policy = UTF8Replace
+	if tok := l.Scan(); tok != FFTok_string || l.Output.String() != "\ufffd" {
+		t.Errorf("strict, UTF8Replace: got %v %q", tok, l.Output)
+	}
+
+	for _, l := range utf8Lexers("\"hé 日本 \U0001f600\"", UTF8Reject) {
+		if tok := l.Scan(); tok != FFTok_string {
+			t.Errorf("valid UTF-8 rejected: %v", l.ScanError())
+		}
+	}
+}
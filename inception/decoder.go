@@ -41,7 +41,7 @@ func CreateUnmarshalJSON(ic *Inception, si *StructInfo) error {
 	if len(si.Fields) > 0 {
 		ic.OutputImports[`"bytes"`] = true
 	}
-	ic.OutputImports[`"fmt"`] = true
+	ic.OutputImports[`"io"`] = true
 
 	out += tplStr(decodeTpl["header"], header{
 		IC: ic,
@@ -64,9 +64,67 @@ func handleField(ic *Inception, name string, typ reflect.Type, ptr bool, quoted
 	return handleFieldAddr(ic, name, false, typ, ptr, quoted)
 }
 
+// handleStructField decodes the struct field sf into name.
+func handleStructField(ic *Inception, name string, sf *StructField) string {
+	if sf.Format == nil || sf.Format.Number {
+		// math/big numbers are read the same whether they were
+		// written as numbers or strings.
+		return handleField(ic, name, sf.Typ, sf.Pointer, sf.ForceString)
+	}
+	return handleFormatted(ic, name, sf.Typ, sf.Pointer, sf.ForceString || sf.Format.Quote, sf.Format)
+}
+
+// handleFormatted decodes a number written as selected by the
+// "ffjson" tag of its field. Only the base matters when reading it
+// back; integers in other bases are always strings.
+func handleFormatted(ic *Inception, name string, typ reflect.Type, ptr bool, quoted bool, nf *numFormat) string {
+	out := fmt.Sprintf("/* handler: %s type=%v kind=%v quoted=%t base=%d*/\n", name, typ, typ.Kind(), quoted, nf.Base)
+
+	var allowed []string
+	parsefunc := "ParseInt"
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64:
+		allowed = buildTokens(quoted, "FFTok_string", "FFTok_double", "FFTok_integer", "FFTok_null")
+		parsefunc = "ParseFloat"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		allowed = buildTokens(quoted, "FFTok_string", "FFTok_integer", "FFTok_null")
+		parsefunc = "ParseUint"
+	default:
+		allowed = buildTokens(quoted, "FFTok_string", "FFTok_integer", "FFTok_null")
+	}
+	if nf.Base != 10 {
+		allowed = []string{"FFTok_string", "FFTok_null"}
+	}
+	out += getAllowTokens(typ.String(), allowed...)
+
+	out += getNumberHandler(ic, name, ptr, typ, parsefunc, nf.Base, quoted)
+	return out
+}
+
 func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type, ptr bool, quoted bool) string {
 	out := fmt.Sprintf("/* handler: %s type=%v kind=%v quoted=%t*/\n", name, typ, typ.Kind(), quoted)
 
+	// math/big numbers implement json.Unmarshaler or encoding.TextUnmarshaler,
+	// but are built from the token directly.
+	if kind := bigNumber(typ); kind != "" {
+		return out + tplStr(decodeTpl["handleBigNumber"], handleBigNumber{
+			Name:     name,
+			Typ:      typ,
+			Kind:     kind,
+			TakeAddr: takeAddr || ptr,
+		})
+	}
+	if typ.Kind() == reflect.Ptr {
+		if kind := bigNumber(typ.Elem()); kind != "" && !takeAddr {
+			return out + tplStr(decodeTpl["handleBigNumber"], handleBigNumber{
+				Name:     name,
+				Typ:      typ.Elem(),
+				Kind:     kind,
+				TakeAddr: true,
+			})
+		}
+	}
+
 	umlx := typ.Implements(unmarshalFasterType) || typeInInception(ic, typ, shared.MustDecoder)
 	umlx = umlx || reflect.PtrTo(typ).Implements(unmarshalFasterType)
 
@@ -95,9 +153,9 @@ func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type
 		reflect.Int64:
 
 		allowed := buildTokens(quoted, "FFTok_string", "FFTok_integer", "FFTok_null")
-		out += getAllowTokens(typ.Name(), allowed...)
+		out += getAllowTokens(typ.String(), allowed...)
 
-		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseInt")
+		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseInt", 10, quoted)
 
 	case reflect.Uint,
 		reflect.Uint8,
@@ -106,24 +164,23 @@ func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type
 		reflect.Uint64:
 
 		allowed := buildTokens(quoted, "FFTok_string", "FFTok_integer", "FFTok_null")
-		out += getAllowTokens(typ.Name(), allowed...)
+		out += getAllowTokens(typ.String(), allowed...)
 
-		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseUint")
+		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseUint", 10, quoted)
 
 	case reflect.Float32,
 		reflect.Float64:
 
 		allowed := buildTokens(quoted, "FFTok_string", "FFTok_double", "FFTok_integer", "FFTok_null")
-		out += getAllowTokens(typ.Name(), allowed...)
+		out += getAllowTokens(typ.String(), allowed...)
 
-		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseFloat")
+		out += getNumberHandler(ic, name, takeAddr || ptr, typ, "ParseFloat", 10, quoted)
 
 	case reflect.Bool:
 		ic.OutputImports[`"bytes"`] = true
-		ic.OutputImports[`"errors"`] = true
 
 		allowed := buildTokens(quoted, "FFTok_string", "FFTok_bool", "FFTok_null")
-		out += getAllowTokens(typ.Name(), allowed...)
+		out += getAllowTokens(typ.String(), allowed...)
 
 		out += tplStr(decodeTpl["handleBool"], handleBool{
 			Name:     name,
@@ -145,14 +202,15 @@ func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type
 
 	case reflect.String:
 		// Is it a json.Number?
-		if typ.PkgPath() == "encoding/json" && typ.Name() == "Number" {
-			// Fall back to json package to rely on the valid number check.
-			// See: https://github.com/golang/go/blob/f05c3aa24d815cd3869153750c9875e35fc48a6e/src/encoding/json/decode.go#L897
-			ic.OutputImports[`"encoding/json"`] = true
-			out += tplStr(decodeTpl["handleFallback"], handleFallback{
-				Name: name,
-				Typ:  typ,
-				Kind: typ.Kind(),
+		if isJsonNumber(typ) {
+			allowed := []string{"FFTok_string", "FFTok_integer", "FFTok_double", "FFTok_null"}
+			out += getAllowTokens(typ.String(), allowed...)
+
+			out += tplStr(decodeTpl["handleJsonNumber"], handleString{
+				IC:       ic,
+				Name:     name,
+				Typ:      typ,
+				TakeAddr: takeAddr || ptr,
 			})
 		} else {
 			out += tplStr(decodeTpl["handleString"], handleString{
@@ -164,12 +222,18 @@ func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type
 			})
 		}
 	case reflect.Interface:
-		ic.OutputImports[`"encoding/json"`] = true
-		out += tplStr(decodeTpl["handleFallback"], handleFallback{
-			Name: name,
-			Typ:  typ,
-			Kind: typ.Kind(),
-		})
+		if typ.NumMethod() != 0 {
+			out += tplStr(decodeTpl["handleFallback"], handleFallback{
+				Name: name,
+				Typ:  typ,
+				Kind: typ.Kind(),
+			})
+		} else {
+			out += tplStr(decodeTpl["handleInterface"], handleInterface{
+				Name:     name,
+				TakeAddr: takeAddr || ptr,
+			})
+		}
 	case reflect.Map:
 		out += tplStr(decodeTpl["handleObject"], handleObject{
 			IC:       ic,
@@ -179,7 +243,6 @@ func handleFieldAddr(ic *Inception, name string, takeAddr bool, typ reflect.Type
 			TakeAddr: takeAddr || ptr,
 		})
 	default:
-		ic.OutputImports[`"encoding/json"`] = true
 		out += tplStr(decodeTpl["handleFallback"], handleFallback{
 			Name: name,
 			Typ:  typ,
@@ -215,7 +278,6 @@ func getArrayHandler(ic *Inception, name string, typ reflect.Type, ptr bool) str
 	if (typ.Elem().Kind() == reflect.Struct || typ.Elem().Kind() == reflect.Map) ||
 		typ.Elem().Kind() == reflect.Array || typ.Elem().Kind() == reflect.Slice &&
 		typ.Elem().Name() == "" {
-		ic.OutputImports[`"encoding/json"`] = true
 
 		return tplStr(decodeTpl["handleFallback"], handleFallback{
 			Name: name,
@@ -252,16 +314,45 @@ func getAllowTokens(name string, tokens ...string) string {
 	})
 }
 
-func getNumberHandler(ic *Inception, name string, takeAddr bool, typ reflect.Type, parsefunc string) string {
+// pathKey returns the expression used in error paths for map key name.
+func pathKey(ic *Inception, typ reflect.Type, name string) string {
+	if typ.Kind() == reflect.String {
+		return "string(" + name + ")"
+	}
+	ic.OutputImports[`"fmt"`] = true
+	return "fmt.Sprint(" + name + ")"
+}
+
+func getNumberHandler(ic *Inception, name string, takeAddr bool, typ reflect.Type, parsefunc string, base int, quoted bool) string {
 	return tplStr(decodeTpl["handlerNumeric"], handlerNumeric{
 		IC:        ic,
 		Name:      name,
 		ParseFunc: parsefunc,
+		Base:      base,
 		TakeAddr:  takeAddr,
 		Typ:       typ,
+		Quoted:    quoted,
 	})
 }
 
+// isJsonNumber reports whether typ is json.Number.
+func isJsonNumber(typ reflect.Type) bool {
+	return typ.PkgPath() == "encoding/json" && typ.Name() == "Number"
+}
+
+// bigNumber returns "Int", "Float" or "Rat" if typ is that type of
+// math/big, and "" otherwise.
+func bigNumber(typ reflect.Type) string {
+	if typ.PkgPath() != "math/big" {
+		return ""
+	}
+	switch typ.Name() {
+	case "Int", "Float", "Rat":
+		return typ.Name()
+	}
+	return ""
+}
+
 func getNumberSize(typ reflect.Type) string {
 	return fmt.Sprintf("%d", typ.Bits())
 }
@@ -32,6 +32,9 @@ func init() {
 		"handlerNumeric":    handlerNumericTxt,
 		"allowTokens":       allowTokensTxt,
 		"handleFallback":    handleFallbackTxt,
+		"handleJsonNumber":  handleJsonNumberTxt,
+		"handleBigNumber":   handleBigNumberTxt,
+		"handleInterface":   handleInterfaceTxt,
 		"handleString":      handleStringTxt,
 		"handleObject":      handleObjectTxt,
 		"handleArray":       handleArrayTxt,
@@ -45,13 +48,15 @@ func init() {
 	}
 
 	tplFuncs := template.FuncMap{
-		"getAllowTokens":  getAllowTokens,
-		"getNumberSize":   getNumberSize,
-		"getType":         getType,
-		"handleField":     handleField,
-		"handleFieldAddr": handleFieldAddr,
-		"unquoteField":    unquoteField,
-		"getTmpVarFor":    getTmpVarFor,
+		"getAllowTokens":    getAllowTokens,
+		"getNumberSize":     getNumberSize,
+		"getType":           getType,
+		"handleField":       handleField,
+		"handleFieldAddr":   handleFieldAddr,
+		"handleStructField": handleStructField,
+		"unquoteField":      unquoteField,
+		"getTmpVarFor":      getTmpVarFor,
+		"pathKey":           pathKey,
 	}
 
 	for k, v := range funcs {
@@ -63,15 +68,22 @@ type handlerNumeric struct {
 	IC        *Inception
 	Name      string
 	ParseFunc string
+	Base      int
 	Typ       reflect.Type
 	TakeAddr  bool
+	Quoted    bool
 }
 
 var handlerNumericTxt = `
 {
 	{{$ic := .IC}}
 
+	{{if eq .Quoted true}}
+	// encoding/json reads the string "null" in a ",string" field as null.
+	if tok == fflib.FFTok_null || tok == fflib.FFTok_string && string(fs.Output.Bytes()) == "null" {
+	{{else}}
 	if tok == fflib.FFTok_null {
+	{{end}}
 		{{if eq .TakeAddr true}}
 		{{.Name}} = nil
 		{{end}}
@@ -79,11 +91,11 @@ var handlerNumericTxt = `
 		{{if eq .ParseFunc "ParseFloat" }}
 		tval, err := fflib.{{ .ParseFunc}}(fs.Output.Bytes(), {{getNumberSize .Typ}})
 		{{else}}
-		tval, err := fflib.{{ .ParseFunc}}(fs.Output.Bytes(), 10, {{getNumberSize .Typ}})
+		tval, err := fflib.{{ .ParseFunc}}(fs.Output.Bytes(), {{.Base}}, {{getNumberSize .Typ}})
 		{{end}}
 
 		if err != nil {
-			return fs.WrapErr(err)
+			return fs.TypeErr(tok, {{printf "%q" .Typ.String}}, err)
 		}
 		{{if eq .TakeAddr true}}
 		ttypval := {{getType $ic .Name .Typ}}(tval)
@@ -95,6 +107,62 @@ var handlerNumericTxt = `
 }
 `
 
+var handleJsonNumberTxt = `
+{
+	{{$ic := .IC}}
+
+	if tok == fflib.FFTok_null {
+	{{if eq .TakeAddr true}}
+		{{.Name}} = nil
+	{{end}}
+	} else {
+		outBuf := fs.Output.Bytes()
+		// encoding/json accepts strings holding a valid number.
+		if tok == fflib.FFTok_string && !fflib.ValidNumber(outBuf) {
+			return fs.TypeErr(tok, {{printf "%q" .Typ.String}}, nil)
+		}
+	{{if eq .TakeAddr true}}
+		tval := {{getType $ic .Name .Typ}}(outBuf)
+		{{.Name}} = &tval
+	{{else}}
+		{{.Name}} = {{getType $ic .Name .Typ}}(outBuf)
+	{{end}}
+	}
+}
+`
+
+type handleBigNumber struct {
+	Name     string
+	Typ      reflect.Type
+	Kind     string
+	TakeAddr bool
+}
+
+var handleBigNumberTxt = `
+{
+	{{if eq .Kind "Int"}}
+	{{getAllowTokens .Typ.String "FFTok_integer" "FFTok_null"}}
+	{{else}}
+	{{getAllowTokens .Typ.String "FFTok_integer" "FFTok_double" "FFTok_string" "FFTok_null"}}
+	{{end}}
+	if tok == fflib.FFTok_null {
+	{{if eq .TakeAddr true}}
+		{{.Name}} = nil
+	{{end}}
+	} else {
+		tval, err := fflib.ParseBig{{.Kind}}(fs.Output.Bytes())
+		if err != nil {
+			return fs.TypeErr(tok, {{printf "%q" .Typ.String}}, err)
+		}
+	{{if eq .TakeAddr true}}
+		{{.Name}} = tval
+	{{else}}
+		{{.Name}}.Set(tval)
+	{{end}}
+	}
+}
+`
+
 type allowTokens struct {
 	Name   string
 	Tokens []string
@@ -103,7 +171,7 @@ type allowTokens struct {
 var allowTokensTxt = `
 {
 	if {{range $index, $element := .Tokens}}{{if ne $index 0 }}&&{{end}} tok != fflib.{{$element}}{{end}} {
-		return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for {{.Name}}", tok))
+		return fs.TypeErr(tok, {{printf "%q" .Name}}, nil)
 	}
 }
 `
@@ -122,13 +190,40 @@ var handleFallbackTxt = `
 		return fs.WrapErr(err)
 	}
 
-	err = json.Unmarshal(tbuf, &{{.Name}})
+	err = fflib.DecodeFallback(tbuf, &{{.Name}})
 	if err != nil {
 		return fs.WrapErr(err)
 	}
 }
 `
 
+type handleInterface struct {
+	Name     string
+	TakeAddr bool
+}
+
+var handleInterfaceTxt = `
+{
+	{{if eq .TakeAddr true}}
+	if tok == fflib.FFTok_null {
+		{{.Name}} = nil
+	} else {
+		tval, err := fs.DecodeInterface(tok)
+		if err != nil {
+			return fs.WrapErr(err)
+		}
+		{{.Name}} = &tval
+	}
+	{{else}}
+	tval, err := fs.DecodeInterface(tok)
+	if err != nil {
+		return fs.WrapErr(err)
+	}
+	{{.Name}} = tval
+	{{end}}
+}
+`
+
 type handleString struct {
 	IC       *Inception
 	Name     string
@@ -141,7 +236,7 @@ var handleStringTxt = `
 {
 	{{$ic := .IC}}
 
-	{{getAllowTokens .Typ.Name "FFTok_string" "FFTok_null"}}
+	{{getAllowTokens .Typ.String "FFTok_string" "FFTok_null"}}
 	if tok == fflib.FFTok_null {
 	{{if eq .TakeAddr true}}
 		{{.Name}} = nil
@@ -173,7 +268,7 @@ type handleObject struct {
 var handleObjectTxt = `
 {
 	{{$ic := .IC}}
-	{{getAllowTokens .Typ.Name "FFTok_left_bracket" "FFTok_null"}}
+	{{getAllowTokens .Typ.String "FFTok_left_bracket" "FFTok_null"}}
 	if tok == fflib.FFTok_null {
 		{{.Name}} = nil
 	} else {
@@ -210,6 +305,7 @@ var handleObjectTxt = `
 
 		wantVal := true
 
+		idx := 0
 		for {
 		{{$keyPtr := false}}
 		{{if eq .Typ.Key.Kind .Ptr }}
@@ -233,36 +329,65 @@ var handleObjectTxt = `
 				goto tokerror
 			}
 			if tok == fflib.FFTok_right_bracket {
+				if wantVal == true && idx > 0 {
+					// this handles things like {"a":1,} as a map value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
+				}
 				break
 			}
 
 			if tok == fflib.FFTok_comma {
 				if wantVal == true {
-					// TODO(pquerna): this isn't an ideal error message, this handles
-					// things like [,,,] as an array value.
-					return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					// this handles things like [,,,] as an array value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
 				}
-				continue
-			} else {
 				wantVal = true
+				continue
+			} else if wantVal == false {
+				return fs.UnexpectedToken(fflib.FFTok_comma, tok)
 			}
 
+			{{if ne .Typ.Key.Kind .Ptr }}
+			keyOffset := fs.TokenOffset()
+			{{end}}
 			{{handleField .IC "k" .Typ.Key $keyPtr false}}
 
 			// Expect ':' after key
 			tok = fs.Scan()
 			if tok != fflib.FFTok_colon {
-				return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				return fs.UnexpectedToken(fflib.FFTok_colon, tok)
 			}
 
+			fs.PushKey({{pathKey .IC .Typ.Key "k"}})
 			tok = fs.Scan()
+			{{if ne .Typ.Key.Kind .Ptr }}
+			if fs.DuplicateKeys != fflib.DuplicateLastWins {
+				{{if eq .TakeAddr true}}
+				_, dup := tval[k]
+				{{else}}
+				_, dup := {{.Name}}[k]
+				{{end}}
+				if dup {
+					err = fs.DuplicateKey({{pathKey .IC .Typ.Key "k"}}, keyOffset, tok)
+					if err != nil {
+						return err
+					}
+					fs.PopPath()
+					idx++
+					wantVal = false
+					continue
+				}
+			}
+			{{end}}
 			{{handleField .IC $tmpVar .Typ.Elem $valPtr false}}
+			fs.PopPath()
 
 			{{if eq .TakeAddr true}}
 			tval[k] = {{$tmpVar}}
 			{{else}}
 			{{.Name}}[k] = {{$tmpVar}}
 			{{end}}
+			idx++
 			wantVal = false
 		}
 
@@ -285,7 +410,7 @@ type handleArray struct {
 var handleArrayTxt = `
 {
 	{{$ic := .IC}}
-	{{getAllowTokens .Typ.Name "FFTok_left_brace" "FFTok_null"}}
+	{{getAllowTokens .Typ.String "FFTok_left_brace" "FFTok_null"}}
 	{{if eq .Typ.Elem.Kind .Ptr}}
 		{{.Name}} = [{{.Typ.Len}}]*{{getType $ic .Name .Typ.Elem.Elem}}{}
 	{{else}}
@@ -310,28 +435,34 @@ var handleArrayTxt = `
 				goto tokerror
 			}
 			if tok == fflib.FFTok_right_brace {
+				if wantVal == true && idx > 0 {
+					// this handles things like [1,] as an array value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
+				}
 				break
 			}
 
 			if tok == fflib.FFTok_comma {
 				if wantVal == true {
-					// TODO(pquerna): this isn't an ideal error message, this handles
-					// things like [,,,] as an array value.
-					return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					// this handles things like [,,,] as an array value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
 				}
-				continue
-			} else {
 				wantVal = true
+				continue
+			} else if wantVal == false {
+				return fs.UnexpectedToken(fflib.FFTok_comma, tok)
 			}
 
+			fs.PushIndex(idx)
 			{{handleField .IC $tmpVar .Typ.Elem $ptr false}}
+			fs.PopPath()
 
 			// Standard json.Unmarshal ignores elements out of array bounds,
 			// that what we do as well.
 			if idx < {{.Typ.Len}} {
 				{{.Name}}[idx] = {{$tmpVar}}
-				idx++
 			}
+			idx++
 
 			wantVal = false
 		}
@@ -342,7 +473,7 @@ var handleArrayTxt = `
 var handleSliceTxt = `
 {
 	{{$ic := .IC}}
-	{{getAllowTokens .Typ.Name "FFTok_left_brace" "FFTok_null"}}
+	{{getAllowTokens .Typ.String "FFTok_left_brace" "FFTok_null"}}
 	if tok == fflib.FFTok_null {
 		{{.Name}} = nil
 	} else {
@@ -362,6 +493,7 @@ var handleSliceTxt = `
 
 		wantVal := true
 
+		idx := 0
 		for {
 			{{$ptr := false}}
 			{{$tmpVar := getTmpVarFor .Name}}
@@ -377,26 +509,33 @@ var handleSliceTxt = `
 				goto tokerror
 			}
 			if tok == fflib.FFTok_right_brace {
+				if wantVal == true && idx > 0 {
+					// this handles things like [1,] as an array value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
+				}
 				break
 			}
 
 			if tok == fflib.FFTok_comma {
 				if wantVal == true {
-					// TODO(pquerna): this isn't an ideal error message, this handles
-					// things like [,,,] as an array value.
-					return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					// this handles things like [,,,] as an array value.
+					return fs.UnexpectedToken(fflib.FFTok_init, tok)
 				}
-				continue
-			} else {
 				wantVal = true
+				continue
+			} else if wantVal == false {
+				return fs.UnexpectedToken(fflib.FFTok_comma, tok)
 			}
 
+			fs.PushIndex(idx)
 			{{handleField .IC $tmpVar .Typ.Elem $ptr false}}
+			fs.PopPath()
 			{{if eq .IsPtr true}}
 				*{{.Name}} = append(*{{.Name}}, {{$tmpVar}})
 			{{else}}
 				{{.Name}} = append({{.Name}}, {{$tmpVar}})
 			{{end}}
+			idx++
 			wantVal = false
 		}
 	}
@@ -405,7 +544,7 @@ var handleSliceTxt = `
 
 var handleByteSliceTxt = `
 {
-	{{getAllowTokens .Typ.Name "FFTok_string" "FFTok_null"}}
+	{{getAllowTokens .Typ.String "FFTok_string" "FFTok_null"}}
 	if tok == fflib.FFTok_null {
 		{{.Name}} = nil
 	} else {
@@ -456,8 +595,7 @@ var handleBoolTxt = `
 			{{.Name}} = false
 		{{end}}
 		} else {
-			err = errors.New("unexpected bytes for true/false value")
-			return fs.WrapErr(err)
+			return fs.TypeErr(tok, {{printf "%q" .Typ.String}}, nil)
 		}
 
 		{{if eq .TakeAddr true}}
@@ -530,9 +668,22 @@ var ujFuncTxt = `
 {{$ic := .IC}}
 
 // UnmarshalJSON umarshall json - template of ffjson
-func (j *{{.SI.Name}}) UnmarshalJSON(input io.Reader) error {
-    fs := fflib.NewFFLexer(input)
-    return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+func (j *{{.SI.Name}}) UnmarshalJSON(input []byte) error {
+	fs := fflib.NewFFLexerBytes(input)
+	defer fs.Release()
+	err := j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+	if err != nil {
+		return err
+	}
+	// Like json.Unmarshal, only whitespace may follow the value.
+	return fs.ExpectEOF()
+}
+
+// UnmarshalJSONReader umarshall json from a reader - template of ffjson
+func (j *{{.SI.Name}}) UnmarshalJSONReader(input io.Reader) error {
+	fs := fflib.NewFFLexer(input)
+	defer fs.Release()
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 }
 
 // UnmarshalJSONFFLexer fast json unmarshall - template ffjson
@@ -542,12 +693,17 @@ func (j *{{.SI.Name}}) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFPar
 	_ = currentKey
 	tok := fflib.FFTok_init
 	wantedTok := fflib.FFTok_init
-
-				{{if eq .ResetFields true}}
+	// afterComma rejects a '}' right after a ',', as in {"a":1,}.
+	afterComma := false
+	// keyOffset is where the current key starts, for DuplicateKeyError.
+	var keyOffset int64
+	_ = keyOffset
+
+				// ffjSet flags track the fields already decoded, for
+				// DuplicateKeys and -reset-fields.
 				{{range $index, $field := $si.Fields}}
 				var ffjSet{{$si.Name}}{{$field.Name}} = false
  				{{end}}
-				{{end}}
 
 mainparse:
 	for {
@@ -570,6 +726,7 @@ mainparse:
 		case fflib.FFParse_after_value:
 			if tok == fflib.FFTok_comma {
 				state = fflib.FFParse_want_key
+				afterComma = true
 			} else if tok == fflib.FFTok_right_bracket {
 				goto done
 			} else {
@@ -579,18 +736,23 @@ mainparse:
 
 		case fflib.FFParse_want_key:
 			// json {} ended. goto exit. woo.
-			if tok == fflib.FFTok_right_bracket {
+			if tok == fflib.FFTok_right_bracket && !afterComma {
 				goto done
 			}
+			afterComma = false
 			if tok != fflib.FFTok_string {
 				wantedTok = fflib.FFTok_string
 				goto wrongtokenerror
 			}
+			keyOffset = fs.TokenOffset()
 
 			kn := fs.Output.Bytes()
 			if len(kn) <= 0 {
 				// "" case. hrm.
 				currentKey = ffjt{{.SI.Name}}nosuchkey
+				if fs.DisallowUnknownFields {
+					goto unknownfielderror
+				}
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			} else {
@@ -613,6 +775,9 @@ mainparse:
 				}
 				{{end}}
 				currentKey = ffjt{{.SI.Name}}nosuchkey
+				if fs.DisallowUnknownFields {
+					goto unknownfielderror
+				}
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			}
@@ -647,29 +812,33 @@ mainparse:
 	}
 {{range $index, $field := $si.Fields}}
 handle_{{$field.Name}}:
+	fs.PushKey({{$field.JsonName}})
+	if ffjSet{{$si.Name}}{{$field.Name}} && fs.DuplicateKeys != fflib.DuplicateLastWins {
+		err = fs.DuplicateKey({{$field.JsonName}}, keyOffset, tok)
+		if err != nil {
+			return err
+		}
+		fs.PopPath()
+		state = fflib.FFParse_after_value
+		goto mainparse
+	}
 	{{with $fieldName := $field.Name | printf "j.%s"}}
-		{{handleField $ic $fieldName $field.Typ $field.Pointer $field.ForceString}}
-		{{if eq $.ResetFields true}}
+		{{handleStructField $ic $fieldName $field}}
+		fs.PopPath()
 		ffjSet{{$si.Name}}{{$field.Name}} = true
-		{{end}}
 		state = fflib.FFParse_after_value
 		goto mainparse
 	{{end}}
 {{end}}
 
 wantedvalue:
-	return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+	return fs.UnexpectedToken(fflib.FFTok_init, tok)
+unknownfielderror:
+	return fs.WrapErr(&fflib.UnknownFieldError{Field: fs.Output.String(), Offset: fs.TokenOffset()})
 wrongtokenerror:
-	return fs.WrapErr(fmt.Errorf("ffjson: wanted token: %v, but got token: %v output=%s", wantedTok, tok, fs.Output.String()))
+	return fs.UnexpectedToken(wantedTok, tok)
 tokerror:
-	if fs.BigError != nil {
-		return fs.WrapErr(fs.BigError)
-	}
-	err = fs.Error.ToError()
-	if err != nil {
-		return fs.WrapErr(err)
-	}
-	panic("ffjson-generated: unreachable, please report bug.")
+	return fs.ScanError()
 done:
 {{if eq .ResetFields true}}
 {{range $index, $field := $si.Fields}}
@@ -20,6 +20,7 @@ package ffjsoninception
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/denys-klymenko-sigma/ffjson/shared"
 )
@@ -83,7 +84,7 @@ func getMapValue(ic *Inception, name string, typ reflect.Type, ptr bool, forceSt
 	if typ.Key().Kind() != reflect.String {
 		out += fmt.Sprintf("/* Falling back. type=%v kind=%v */\n", typ, typ.Kind())
 		out += ic.q.Flush()
-		out += "err = buf.Encode(" + name + ")" + "\n"
+		out += "err = fflib.EncodeFallback(buf, " + name + ")" + "\n"
 		out += "if err != nil {" + "\n"
 		out += "  return err" + "\n"
 		out += "}" + "\n"
@@ -92,6 +93,10 @@ func getMapValue(ic *Inception, name string, typ reflect.Type, ptr bool, forceSt
 
 	var elemKind reflect.Kind
 	elemKind = typ.Elem().Kind()
+	if bigNumber(typ.Elem()) != "" || elemKind == reflect.Ptr && bigNumber(typ.Elem().Elem()) != "" {
+		// math/big numbers are written directly, like the basic kinds.
+		elemKind = reflect.String
+	}
 
 	switch elemKind {
 	case reflect.String,
@@ -108,21 +113,27 @@ func getMapValue(ic *Inception, name string, typ reflect.Type, ptr bool, forceSt
 		out += ic.q.GetQueued()
 		ic.q.DeleteLast()
 		out += "} else {" + "\n"
-		out += ic.q.WriteFlush("{ ")
+		out += ic.q.WriteFlush("{")
+		out += "  first := true" + "\n"
 		out += "  for key, value := range " + name + " {" + "\n"
+		out += "    if !first {" + "\n"
+		out += "      buf.WriteByte(',')" + "\n"
+		out += "    }" + "\n"
+		out += "    first = false" + "\n"
 		out += "    fflib.WriteJsonString(buf, key)" + "\n"
 		out += "    buf.WriteString(`:`)" + "\n"
-		out += getGetInnerValue(ic, "value", typ.Elem(), false, forceString)
-		out += "    buf.WriteByte(',')" + "\n"
+		// Map values are not quoted for ",string", so only the string
+		// double-escaping applies to them.
+		out += getGetInnerValue(ic, "value", typ.Elem(), false, forceString && typ.Elem().Kind() == reflect.String)
+		out += ic.q.Flush()
 		out += "  }" + "\n"
-		out += "buf.Rewind(1)" + "\n"
 		out += ic.q.WriteFlush("}")
 		out += "}" + "\n"
 
 	default:
 		out += ic.q.Flush()
 		out += fmt.Sprintf("/* Falling back. type=%v kind=%v */\n", typ, typ.Kind())
-		out += "err = buf.Encode(" + name + ")" + "\n"
+		out += "err = fflib.EncodeFallback(buf, " + name + ")" + "\n"
 		out += "if err != nil {" + "\n"
 		out += "  return err" + "\n"
 		out += "}" + "\n"
@@ -138,6 +149,25 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 		out += ic.q.Flush()
 	}
 
+	// math/big numbers are written directly rather than through
+	// their MarshalJSON or MarshalText.
+	if kind := bigNumber(typ); kind != "" {
+		arg := "&" + name
+		if ptr {
+			arg = name
+		}
+		return out + getBigValue(ic, kind, arg, false)
+	}
+	if typ.Kind() == reflect.Ptr {
+		if kind := bigNumber(typ.Elem()); kind != "" {
+			arg := name
+			if ptr {
+				arg = "*" + name
+			}
+			return out + getBigValue(ic, kind, arg, false)
+		}
+	}
+
 	if typ.Implements(marshalerFasterType) ||
 		reflect.PtrTo(typ).Implements(marshalerFasterType) ||
 		typeInInception(ic, typ, shared.MustEncoder) ||
@@ -179,10 +209,16 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 		out += "fflib.FormatBits2(buf, uint64(" + ptname + "), 10, false)" + "\n"
 	case reflect.Float32:
 		ic.OutputImports[`fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"`] = true
-		out += "fflib.AppendFloat(buf, float64(" + ptname + "), 'g', -1, 32)" + "\n"
+		out += "err = fflib.WriteFloat(buf, float64(" + ptname + "), 32, " + strconv.FormatBool(forceString) + ")" + "\n"
+		out += "if err != nil {" + "\n"
+		out += "  return err" + "\n"
+		out += "}" + "\n"
 	case reflect.Float64:
 		ic.OutputImports[`fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"`] = true
-		out += "fflib.AppendFloat(buf, float64(" + ptname + "), 'g', -1, 64)" + "\n"
+		out += "err = fflib.WriteFloat(buf, float64(" + ptname + "), 64, " + strconv.FormatBool(forceString) + ")" + "\n"
+		out += "if err != nil {" + "\n"
+		out += "  return err" + "\n"
+		out += "}" + "\n"
 	case reflect.Array,
 		reflect.Slice:
 
@@ -226,11 +262,10 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 		}
 	case reflect.String:
 		// Is it a json.Number?
-		if typ.PkgPath() == "encoding/json" && typ.Name() == "Number" {
-			// Fall back to json package to rely on the valid number check.
-			// See: https://github.com/golang/go/blob/92cd6e3af9f423ab4d8ac78f24e7fd81c31a8ce6/src/encoding/json/encode.go#L550
+		if isJsonNumber(typ) {
+			ic.OutputImports[`fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"`] = true
 			out += fmt.Sprintf("/* json.Number */\n")
-			out += "err = buf.Encode(" + name + ")" + "\n"
+			out += "err = fflib.WriteJsonNumber(buf, " + ptname + ", " + strconv.FormatBool(forceString) + ")" + "\n"
 			out += "if err != nil {" + "\n"
 			out += "  return err" + "\n"
 			out += "}" + "\n"
@@ -242,7 +277,12 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 				out += "{" + "\n"
 				out += "tmpbuf := fflib.Buffer{}" + "\n"
 				out += "tmpbuf.Grow(len(" + ptname + ") + 16)" + "\n"
+				out += "tmpbuf.SetEscapeHTML(fflib.EscapeHTML(buf))" + "\n"
+				out += "tmpbuf.SetUTF8Policy(fflib.UTF8PolicyOf(buf))" + "\n"
 				out += "fflib.WriteJsonString(&tmpbuf, string(" + ptname + "))" + "\n"
+				out += "if err := tmpbuf.UTF8Err(); err != nil {" + "\n"
+				out += "  return err" + "\n"
+				out += "}" + "\n"
 				out += "fflib.WriteJsonString(buf, string( tmpbuf.Bytes() " + `))` + "\n"
 				out += "}" + "\n"
 			} else {
@@ -271,7 +311,7 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 		out += "}" + "\n"
 	case reflect.Interface:
 		out += fmt.Sprintf("/* Interface types must use runtime reflection. type=%v kind=%v */\n", typ, typ.Kind())
-		out += "err = buf.Encode(" + name + ")" + "\n"
+		out += "err = fflib.EncodeFallback(buf, " + name + ")" + "\n"
 		out += "if err != nil {" + "\n"
 		out += "  return err" + "\n"
 		out += "}" + "\n"
@@ -280,33 +320,30 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 	case reflect.Struct:
 		if typ.Name() == "" {
 			ic.q.Write("{")
-			ic.q.Write(" ")
 			out += fmt.Sprintf("/* Inline struct. type=%v kind=%v */\n", typ, typ.Kind())
+			// The fields go in their own block, so they have their own
+			// wroteField variable.
+			out += "{" + "\n"
 			newV := reflect.Indirect(reflect.New(typ)).Interface()
 			fields := extractFields(newV)
 
 			// Output all fields
-			for _, field := range fields {
+			sep := sepNone
+			for i, field := range fields {
 				// Adjust field name
 				field.Name = name + "." + field.Name
-				out += getField(ic, field, "")
-			}
-
-			if lastConditional(fields) {
-				out += ic.q.Flush()
-				out += `buf.Rewind(1)` + "\n"
-			} else {
-				ic.q.DeleteLast()
+				out += getField(ic, field, "", &sep, i == len(fields)-1)
 			}
 			out += ic.q.WriteFlush("}")
+			out += "}" + "\n"
 		} else {
 			out += fmt.Sprintf("/* Struct fall back. type=%v kind=%v */\n", typ, typ.Kind())
 			out += ic.q.Flush()
 			if ptr {
-				out += "err = buf.Encode(" + name + ")" + "\n"
+				out += "err = fflib.EncodeFallback(buf, " + name + ")" + "\n"
 			} else {
 				// We send pointer to avoid copying entire struct
-				out += "err = buf.Encode(&" + name + ")" + "\n"
+				out += "err = fflib.EncodeFallback(buf, &" + name + ")" + "\n"
 			}
 			out += "if err != nil {" + "\n"
 			out += "  return err" + "\n"
@@ -314,7 +351,7 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 		}
 	default:
 		out += fmt.Sprintf("/* Falling back. type=%v kind=%v */\n", typ, typ.Kind())
-		out += "err = buf.Encode(" + name + ")" + "\n"
+		out += "err = fflib.EncodeFallback(buf, " + name + ")" + "\n"
 		out += "if err != nil {" + "\n"
 		out += "  return err" + "\n"
 		out += "}" + "\n"
@@ -323,9 +360,66 @@ func getGetInnerValue(ic *Inception, name string, typ reflect.Type, ptr bool, fo
 	return out
 }
 
+// getBigValue writes the math/big number arg, of type *big.Int,
+// *big.Float or *big.Rat as given by kind. Like encoding/json,
+// big.Float and big.Rat are written as strings unless number is set
+// by the "ffjson" tag of their field.
+func getBigValue(ic *Inception, kind string, arg string, number bool) string {
+	ic.OutputImports[`fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"`] = true
+	out := ic.q.Flush()
+	if kind == "Int" {
+		out += "err = fflib.WriteBigInt(buf, " + arg + ")" + "\n"
+	} else {
+		out += "err = fflib.WriteBig" + kind + "(buf, " + arg + ", " + strconv.FormatBool(!number) + ")" + "\n"
+	}
+	out += "if err != nil {" + "\n"
+	out += "  return err" + "\n"
+	out += "}" + "\n"
+	return out
+}
+
+// getFormattedValue writes a number as selected by the "ffjson" tag
+// of its field.
+func getFormattedValue(ic *Inception, name string, typ reflect.Type, ptr bool, quoted bool, nf *numFormat) string {
+	if nf.Number {
+		arg := "&" + name
+		if ptr {
+			arg = name
+		}
+		return getBigValue(ic, bigNumber(typ), arg, true)
+	}
+
+	ic.OutputImports[`fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"`] = true
+	out := ic.q.Flush()
+
+	ptname := name
+	if ptr {
+		ptname = "*" + name
+	}
+
+	switch typ.Kind() {
+	case reflect.Float32, reflect.Float64:
+		bits := strconv.Itoa(typ.Bits())
+		if nf.Fmt == 0 {
+			out += "err = fflib.WriteFloat(buf, float64(" + ptname + "), " + bits + ", " + strconv.FormatBool(quoted) + ")" + "\n"
+		} else {
+			out += "err = fflib.WriteFloatFormat(buf, float64(" + ptname + "), '" + string(nf.Fmt) + "', " + strconv.Itoa(nf.Prec) + ", " + bits + ", " + strconv.FormatBool(quoted) + ")" + "\n"
+		}
+		out += "if err != nil {" + "\n"
+		out += "  return err" + "\n"
+		out += "}" + "\n"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out += "fflib.FormatBits2(buf, uint64(" + ptname + "), " + strconv.Itoa(nf.Base) + ", " + ptname + " < 0)" + "\n"
+	default:
+		out += "fflib.FormatBits2(buf, uint64(" + ptname + "), " + strconv.Itoa(nf.Base) + ", false)" + "\n"
+	}
+	return out
+}
+
 func getValue(ic *Inception, sf *StructField, prefix string) string {
+	forceString := sf.ForceString || sf.Format != nil && sf.Format.Quote
 	closequote := false
-	if sf.ForceString {
+	if forceString {
 		switch sf.Typ.Kind() {
 		case reflect.Int,
 			reflect.Int8,
@@ -345,7 +439,12 @@ func getValue(ic *Inception, sf *StructField, prefix string) string {
 			closequote = true
 		}
 	}
-	out := getGetInnerValue(ic, prefix+sf.Name, sf.Typ, sf.Pointer, sf.ForceString)
+	var out string
+	if sf.Format != nil {
+		out = getFormattedValue(ic, prefix+sf.Name, sf.Typ, sf.Pointer, forceString, sf.Format)
+	} else {
+		out = getGetInnerValue(ic, prefix+sf.Name, sf.Typ, sf.Pointer, forceString)
+	}
 	if closequote {
 		if sf.Pointer {
 			out += ic.q.WriteFlush(`"`)
@@ -431,15 +530,49 @@ func isIntish(t reflect.Type) bool {
 	return false
 }
 
-func getField(ic *Inception, f *StructField, prefix string) string {
+// fieldSep tells, while the fields of an object are generated,
+// whether a comma must be written before the next one.
+// Commas are written before fields rather than after them,
+// so the output never has to be rewound.
+type fieldSep int
+
+const (
+	// No field can have been written yet.
+	sepNone fieldSep = iota
+	// A field has always been written.
+	sepAlways
+	// The wroteField variable tells if a field has been written.
+	sepMaybe
+)
+
+func getSep(ic *Inception, sep fieldSep) string {
+	switch sep {
+	case sepAlways:
+		ic.q.Write(",")
+	case sepMaybe:
+		out := ic.q.Flush()
+		out += "if wroteField {" + "\n"
+		out += "buf.WriteByte(',')" + "\n"
+		out += "}" + "\n"
+		return out
+	}
+	return ""
+}
+
+func getField(ic *Inception, f *StructField, prefix string, sep *fieldSep, last bool) string {
 	out := ""
 	if f.OmitEmpty {
 		out += ic.q.Flush()
+		// Later fields need to know if this one was written.
+		if *sep == sepNone && !last {
+			out += "wroteField := false" + "\n"
+		}
 		if f.Pointer {
 			out += "if " + prefix + f.Name + " != nil {" + "\n"
 		}
 		out += getOmitEmpty(ic, f)
 	}
+	out += getSep(ic, *sep)
 
 	if f.Pointer && !f.OmitEmpty {
 		// Pointer values encode as the value pointed to. A nil pointer encodes as the null JSON object.
@@ -453,7 +586,6 @@ func getField(ic *Inception, f *StructField, prefix string) string {
 	t := ic.q
 
 	out += getValue(ic, f, prefix)
-	ic.q.Write(",")
 
 	if f.Pointer && !f.OmitEmpty {
 		out += "} else {" + "\n"
@@ -463,25 +595,23 @@ func getField(ic *Inception, f *StructField, prefix string) string {
 
 	if f.OmitEmpty {
 		out += ic.q.Flush()
+		if *sep != sepAlways && !last {
+			out += "wroteField = true" + "\n"
+		}
 		if f.Pointer {
 			out += "}" + "\n"
 		}
 		out += "}" + "\n"
+		if *sep == sepNone {
+			*sep = sepMaybe
+		}
+	} else {
+		*sep = sepAlways
 	}
 	return out
 }
 
-// We check if the last field is conditional.
-func lastConditional(fields []*StructField) bool {
-	if len(fields) > 0 {
-		f := fields[len(fields)-1]
-		return f.OmitEmpty
-	}
-	return false
-}
-
 func CreateMarshalJSON(ic *Inception, si *StructInfo) error {
-	conditionalWrites := lastConditional(si.Fields)
 	out := ""
 
 	out += "// MarshalJSON marshal bytes to json - template\n"
@@ -514,30 +644,13 @@ func CreateMarshalJSON(ic *Inception, si *StructInfo) error {
 
 	ic.q.Write("{")
 
-	// The extra space is inserted here.
-	// If nothing is written to the field this will be deleted
-	// instead of the last comma.
-	if conditionalWrites || len(si.Fields) == 0 {
-		ic.q.Write(" ")
-	}
-
-	for _, f := range si.Fields {
-		out += getField(ic, f, "j.")
-	}
-
-	// Handling the last comma is tricky.
-	// If the last field has omitempty, conditionalWrites is set.
-	// If something has been written, we delete the last comma,
-	// by backing up the buffer, otherwise it will delete a space.
-	if conditionalWrites {
-		out += ic.q.Flush()
-		out += `buf.Rewind(1)` + "\n"
-	} else {
-		ic.q.DeleteLast()
+	sep := sepNone
+	for i, f := range si.Fields {
+		out += getField(ic, f, "j.", &sep, i == len(si.Fields)-1)
 	}
 
 	out += ic.q.WriteFlush("}")
-	out += `return nil` + "\n"
+	out += `return fflib.UTF8Err(buf)` + "\n"
 	out += `}` + "\n"
 	ic.OutputFuncs = append(ic.OutputFuncs, out)
 	return nil
@@ -104,6 +104,10 @@ func (i *Inception) generateCode() error {
 	sorted.Sort()
 
 	for _, si := range sorted {
+		if err := si.parseFormats(); err != nil {
+			return err
+		}
+
 		if i.wantMarshal(si) {
 			err := CreateMarshalJSON(i, si)
 			if err != nil {
@@ -38,6 +38,8 @@ type StructField struct {
 	HasUnmarshalJSON bool
 	Pointer          bool
 	Tagged           bool
+	FFTag            string
+	Format           *numFormat
 }
 
 type FieldByJsonName []*StructField
@@ -200,6 +202,7 @@ func extractFields(obj interface{}) []*StructField {
 						ForceString:      opts.Contains("string"),
 						Pointer:          ptr,
 						Tagged:           tagged,
+						FFTag:            sf.Tag.Get("ffjson"),
 					}
 
 					fields = append(fields, field)
@@ -18,6 +18,9 @@
 package ffjsoninception
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -77,3 +80,93 @@ func isValidTag(s string) bool {
 	}
 	return true
 }
+
+// numFormat is the formatting selected for a numeric field by its
+// "ffjson" tag, for example `ffjson:"prec=2"` or `ffjson:"base=16"`.
+type numFormat struct {
+	// Fmt is 'e', 'f' or 'g', or 0 for the format of encoding/json.
+	Fmt byte
+	// Prec is the precision for Fmt, or -1 for the fewest digits
+	// that read back to the same value.
+	Prec int
+	// Base is the base of integers.
+	Base int
+	// Quote writes the value as a JSON string. Integers in a base
+	// other than 10 are always quoted.
+	Quote bool
+	// Number writes a big.Float or big.Rat as a JSON number, where
+	// encoding/json writes a string.
+	Number bool
+}
+
+// parseNumFormat parses the "ffjson" tag of a field of type typ. It
+// returns nil if the tag is empty.
+func parseNumFormat(tag string, typ reflect.Type) (*numFormat, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	if kind := bigNumber(typ); kind == "Float" || kind == "Rat" {
+		if tag != "number" {
+			return nil, fmt.Errorf("ffjson tag %q: only the number option is supported for %v", tag, typ)
+		}
+		return &numFormat{Number: true}, nil
+	}
+	isInt, isFloat := false, false
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		isInt = true
+	case reflect.Float32, reflect.Float64:
+		isFloat = true
+	default:
+		return nil, fmt.Errorf("ffjson tag %q needs an integer or float field, not %v", tag, typ)
+	}
+
+	nf := &numFormat{Prec: -1, Base: 10}
+	hasPrec := false
+	for _, opt := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(opt, "=")
+		var err error
+		switch {
+		case opt == "string":
+			nf.Quote = true
+		case key == "fmt" && isFloat && (val == "e" || val == "f" || val == "g"):
+			nf.Fmt = val[0]
+		case key == "prec" && isFloat:
+			nf.Prec, err = strconv.Atoi(val)
+			if err == nil && nf.Prec < 0 {
+				err = strconv.ErrRange
+			}
+			hasPrec = true
+		case key == "base" && isInt:
+			nf.Base, err = strconv.Atoi(val)
+			if err == nil && (nf.Base < 2 || nf.Base > 36) {
+				err = strconv.ErrRange
+			}
+		default:
+			return nil, fmt.Errorf("ffjson tag %q: unsupported option %q for %v", tag, opt, typ)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ffjson tag %q: invalid option %q: %v", tag, opt, err)
+		}
+	}
+	if hasPrec && nf.Fmt == 0 {
+		nf.Fmt = 'f'
+	}
+	if nf.Base != 10 {
+		nf.Quote = true
+	}
+	return nf, nil
+}
+
+// parseFormats sets the numFormat of each field from its "ffjson" tag.
+func (si *StructInfo) parseFormats() error {
+	for _, sf := range si.Fields {
+		nf, err := parseNumFormat(sf.FFTag, sf.Typ)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", si.Name, sf.Name, err)
+		}
+		sf.Format = nf
+	}
+	return nil
+}
@@ -21,6 +21,8 @@ import (
 	"errors"
 	"math"
 	"time"
+
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
 )
 
 // FFFoo struc... just  blah
@@ -2305,3 +2307,124 @@ type XDominantField struct {
 	Name  *int             `json",omitempty"`
 	A     *struct{ X int } `json:"Name,omitempty"`
 }
+
+// TInterfaces struct
+// ffjson: skip
+type TInterfaces struct {
+	I interface{}
+	P *interface{}
+	M map[string]interface{}
+	S []interface{}
+}
+
+// XInterfaces struct
+type XInterfaces struct {
+	I interface{}
+	P *interface{}
+	M map[string]interface{}
+	S []interface{}
+}
+
+// Foreign stands in for a type from another package,
+// which can only be handled by a registered codec.
+// ffjson: skip
+type Foreign struct {
+	A int
+	B string
+}
+
+// XCodec struct
+type XCodec struct {
+	F Foreign
+	P *Foreign
+	N *Foreign
+	S []Foreign
+}
+
+// Point is decoded from [x, y] by a handwritten decoder
+// using the token API of the lexer.
+// ffjson: skip
+type Point struct {
+	X float64
+	Y float64
+}
+
+// UnmarshalJSONFFLexer decodes a Point from [x, y].
+func (p *Point) UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error {
+	l.Start(state)
+	kind, err := l.Next()
+	if err != nil || kind == fflib.KindNull {
+		return err
+	}
+	if kind != fflib.KindArrayStart {
+		return l.TypeErr(l.Token, "tff.Point", nil)
+	}
+	if p.X, err = l.ReadFloat64(); err != nil {
+		return err
+	}
+	if p.Y, err = l.ReadFloat64(); err != nil {
+		return err
+	}
+	if kind, err = l.Next(); err != nil {
+		return err
+	}
+	if kind != fflib.KindArrayEnd {
+		return l.UnexpectedToken(fflib.FFTok_right_brace, l.Token)
+	}
+	return nil
+}
+
+// XPoints struct
+type XPoints struct {
+	Name string
+	P    Point
+	Q    *Point
+	S    []Point
+}
+
+// XStrict struct
+type XStrict struct {
+	X []int
+	Y map[string]int
+	Z string
+}
+
+// XConfig struct
+type XConfig struct {
+	Name   string
+	Port   int
+	Ratio  float64
+	Tags   []string
+	Limits map[string]int
+	Extra  interface{}
+	Nested *XConfig
+}
+
+// XDuplicate struct
+type XDuplicate struct {
+	ID     int `json:"id"`
+	Name   string
+	Counts map[string]int
+	Extra  interface{}
+}
+
+// TFloats struct
+// ffjson: skip
+type TFloats struct {
+	F32    float32
+	F64    float64
+	Ptr    *float64
+	Slice  []float64
+	Map    map[string]float64
+	Quoted float64 `json:",string"`
+}
+
+// XFloats struct
+type XFloats struct {
+	F32    float32
+	F64    float64
+	Ptr    *float64
+	Slice  []float64
+	Map    map[string]float64
+	Quoted float64 `json:",string"`
+}
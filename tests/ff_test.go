@@ -20,11 +20,19 @@ package tff
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/denys-klymenko-sigma/ffjson/ffjson"
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
 )
 
 // If this is enabled testSameMarshal and testCycle will output failures to files
@@ -213,6 +221,826 @@ func TestUnmarshalFaster(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestStreamDecoder(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 50; i++ {
+		r := newLogFFRecord()
+		r.Timestamp = int64(i)
+		r.ReqID = strings.Repeat("x", i)
+		buf, err := ffjson.Marshal(r)
+		require.NoError(t, err)
+		input.Write(buf)
+		input.WriteByte('\n')
+	}
+	input.WriteString(`{"id": 1, "OriginID": 22} trailing`)
+
+	dec := ffjson.NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(input.Bytes())))
+	defer dec.Release()
+	for i := 0; i < 50; i++ {
+		require.True(t, dec.More())
+		var r FFRecord
+		err := dec.Decode(&r)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), r.Timestamp)
+		require.Equal(t, strings.Repeat("x", i), r.ReqID)
+	}
+
+	// Types without generated code are decoded from the same stream.
+	var r Record
+	err := dec.Decode(&r)
+	require.NoError(t, err)
+	require.Equal(t, uint32(22), r.OriginID)
+	require.Equal(t, int64(strings.LastIndex(input.String(), " trailing")), dec.InputOffset())
+
+	// One byte at a time, nothing past the value has been read yet.
+	rest, err := io.ReadAll(dec.Buffered())
+	require.NoError(t, err)
+	require.Empty(t, rest)
+
+	// From a reader that fills the buffer, the rest of it is kept.
+	bufDec := ffjson.NewStreamDecoder(strings.NewReader(`{"id": 1} trailing`))
+	defer bufDec.Release()
+	require.NoError(t, bufDec.Decode(&FFRecord{}))
+	rest, err = io.ReadAll(bufDec.Buffered())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(rest), " trailing"), "%q", rest)
+
+	require.True(t, dec.More())
+	err = dec.Decode(&r)
+	require.Error(t, err)
+	require.False(t, dec.More())
+}
+
+func TestStreamDecoderEOF(t *testing.T) {
+	dec := ffjson.NewStreamDecoder(strings.NewReader("{\"id\": 1}\n\n"))
+	var r FFRecord
+	require.NoError(t, dec.Decode(&r))
+	require.False(t, dec.More())
+	require.Equal(t, io.EOF, dec.Decode(&r))
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	dec := ffjson.NewDecoder()
+	var r FFRecord
+	err := dec.Decode(strings.NewReader(`{"id": 1, "unknown": 2}`), &r)
+	require.NoError(t, err)
+
+	dec.DisallowUnknownFields()
+	err = dec.Decode(strings.NewReader(`{"id": 1, "meth": "GET"}`), &r)
+	require.NoError(t, err)
+
+	err = dec.Decode(strings.NewReader(`{"id": 1, "unknown": 2}`), &r)
+	var uerr *fflib.UnknownFieldError
+	require.True(t, errors.As(err, &uerr), "unexpected error: %v", err)
+	require.Equal(t, "unknown", uerr.Field)
+	require.Equal(t, int64(10), uerr.Offset)
+
+	// Nested generated types share the setting.
+	err = dec.Decode(strings.NewReader(`{"Bar": {"Blah": 1, "Blub": 2}}`), &r)
+	require.True(t, errors.As(err, &uerr), "unexpected error: %v", err)
+	require.Equal(t, "Blub", uerr.Field)
+	require.Equal(t, int64(20), uerr.Offset)
+
+	// Types without generated code use encoding/json with the same setting.
+	err = dec.Decode(strings.NewReader(`{"unknown": 2}`), newLogRecord())
+	require.Error(t, err)
+
+	sdec := ffjson.NewStreamDecoder(strings.NewReader(`{"id": 1} {"x": 1}`))
+	sdec.DisallowUnknownFields()
+	require.NoError(t, sdec.Decode(&r))
+	err = sdec.Decode(&r)
+	require.True(t, errors.As(err, &uerr), "unexpected error: %v", err)
+	require.Equal(t, "x", uerr.Field)
+	require.Equal(t, int64(11), uerr.Offset)
+}
+
+const interfacesJSON = `{
+	"I": {"a": [1, 2.5, "x", null, true, {}], "b": {"c": -3e2}},
+	"P": 9007199254740993,
+	"M": {"n": 12345678901234567, "s": "\u00e9", "l": []},
+	"S": [false, [[]], {"k": 0.1}]
+}`
+
+func TestUnmarshalInterfaces(t *testing.T) {
+	var base TInterfaces
+	err := json.Unmarshal([]byte(interfacesJSON), &base)
+	require.NoError(t, err)
+
+	var ff XInterfaces
+	err = ffjson.UnmarshalBytesFast([]byte(interfacesJSON), &ff)
+	require.NoError(t, err)
+	require.Equal(t, base.I, ff.I)
+	require.Equal(t, *base.P, *ff.P)
+	require.Equal(t, base.M, ff.M)
+	require.Equal(t, base.S, ff.S)
+
+	err = ffjson.UnmarshalBytesFast([]byte(`{"I": null, "P": null}`), &ff)
+	require.NoError(t, err)
+	require.Nil(t, ff.I)
+	require.Nil(t, ff.P)
+}
+
+func TestUnmarshalInterfacesNumbers(t *testing.T) {
+	dec := ffjson.NewDecoder()
+	dec.UseNumber()
+	var ff XInterfaces
+	err := dec.DecodeFast(strings.NewReader(interfacesJSON), &ff)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("9007199254740993"), *ff.P)
+	require.Equal(t, json.Number("-3e2"), ff.I.(map[string]interface{})["b"].(map[string]interface{})["c"])
+
+	dec = ffjson.NewDecoder()
+	dec.UseInt64()
+	err = dec.DecodeFast(strings.NewReader(interfacesJSON), &ff)
+	require.NoError(t, err)
+	require.Equal(t, int64(9007199254740993), *ff.P)
+	require.Equal(t, int64(12345678901234567), ff.M["n"])
+	require.Equal(t, -3e2, ff.I.(map[string]interface{})["b"].(map[string]interface{})["c"])
+	require.Equal(t, []interface{}{int64(1), 2.5, "x", nil, true, map[string]interface{}{}},
+		ff.I.(map[string]interface{})["a"])
+}
+
+func TestUnmarshalJSONStd(t *testing.T) {
+	buf := []byte(`{"id": 123213, "OriginID": 22, "meth": "GET"}`)
+	record := newLogFFRecord()
+	require.Implements(t, (*json.Unmarshaler)(nil), record)
+
+	err := json.Unmarshal(buf, record)
+	require.NoError(t, err)
+	require.Equal(t, int64(123213), record.Timestamp)
+	require.Equal(t, "GET", record.Method)
+
+	r2 := newLogFFRecord()
+	err = r2.UnmarshalJSONReader(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, record, r2)
+
+	// Called directly, it rejects trailing data as json.Unmarshal does.
+	require.NoError(t, record.UnmarshalJSON(append(buf, " \t\n"...)))
+	var x Xslice
+	for _, input := range []string{`{"X":[1]} junk`, `{"X":[1]}{}`, `{"X":[1]}]`} {
+		require.Error(t, x.UnmarshalJSON([]byte(input)), input)
+	}
+}
+
+func TestUnmarshalBytes(t *testing.T) {
+	buf := []byte(`{"id": 123213, "OriginID": 22, "meth": "GET"}`)
+	orig := string(buf)
+	record := newLogFFRecord()
+	err := ffjson.UnmarshalBytesFast(buf, record)
+	require.NoError(t, err)
+	require.Equal(t, int64(123213), record.Timestamp)
+	require.Equal(t, uint32(22), record.OriginID)
+	require.Equal(t, "GET", record.Method)
+	require.Equal(t, orig, string(buf), "input must not be modified")
+
+	r2 := newLogRecord()
+	err = ffjson.UnmarshalBytesFast(buf, r2)
+	require.Error(t, err, "Record should not support UnmarshalBytesFast")
+	err = ffjson.UnmarshalBytes(buf, r2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(22), r2.OriginID)
+
+	// Like json.Unmarshal, only whitespace may follow the value.
+	require.NoError(t, ffjson.UnmarshalBytes([]byte(orig+" \n"), record))
+	for _, input := range []string{orig + " junk", orig + "{}", `{"X":[1]} junk`} {
+		require.Error(t, json.Unmarshal([]byte(input), &Tslice{}), input)
+		require.Error(t, ffjson.UnmarshalBytes([]byte(input), record), input)
+		require.Error(t, ffjson.UnmarshalBytes([]byte(input), r2), input)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	x := &XEmbeddedStructures{
+		X: []interface{}{1, "a,b", map[string]interface{}{"k": []int{}}},
+		Q: [][]string{{"x"}, {}},
+	}
+	x.Y.X = 1
+	x.Z = append(x.Z, struct{ X int }{2})
+	x.U = map[string]struct{ X int }{"u": {3}}
+	x.V = []map[string]struct{ X int }{{"v": {4}}, {}}
+
+	expect, err := json.MarshalIndent(x, ">", "\t")
+	require.NoError(t, err)
+	out, err := ffjson.MarshalIndent(x, ">", "\t")
+	require.NoError(t, err)
+	require.Equal(t, string(expect), string(out))
+
+	var w bytes.Buffer
+	enc := ffjson.NewEncoder(&w)
+	enc.SetIndent("", "  ")
+	require.NoError(t, enc.Encode(x))
+	require.NoError(t, enc.Encode(&XRenameTypes{}))
+	expect, err = json.MarshalIndent(x, "", "  ")
+	require.NoError(t, err)
+	second, err := json.MarshalIndent(&XRenameTypes{}, "", "  ")
+	require.NoError(t, err)
+	require.Equal(t, string(expect)+string(second), w.String())
+
+	w.Reset()
+	enc.SetIndent("", "")
+	require.NoError(t, enc.EncodeFast(x))
+	expect, err = ffjson.Marshal(x)
+	require.NoError(t, err)
+	require.Equal(t, string(expect), w.String())
+}
+
+func TestIndentBufferRewind(t *testing.T) {
+	var out fflib.Buffer
+	buf := fflib.NewIndentBuffer(&out, "", " ")
+	buf.WriteString(`{ `)
+	require.NoError(t, buf.Rewind(1))
+	buf.WriteString(`}`)
+	require.Equal(t, `{}`, out.String())
+
+	buf.Reset()
+	buf.WriteString(`{"a":[1,`)
+	require.NoError(t, buf.Rewind(1))
+	buf.WriteString(`],"b":"x\",y",`)
+	require.NoError(t, buf.Rewind(1))
+	buf.WriteByte('}')
+	require.Equal(t, "{\n \"a\": [\n  1\n ],\n \"b\": \"x\\\",y\"\n}", out.String())
+	require.Error(t, buf.Rewind(1))
+}
+
+func TestEncoderSetEscapeHTML(t *testing.T) {
+	const html = `<a href="x?a=1&b=2">`
+	values := []struct{ base, ff interface{} }{
+		{&Tstring{X: html}, &Xstring{X: html}},
+		{&TstringTagged{X: html}, &XstringTagged{X: html}},
+		{&TInterfaces{I: html, M: map[string]interface{}{"m": html}}, &XInterfaces{I: html, M: map[string]interface{}{"m": html}}},
+	}
+
+	for _, escape := range []bool{true, false} {
+		for _, v := range values {
+			var expect bytes.Buffer
+			jenc := json.NewEncoder(&expect)
+			jenc.SetEscapeHTML(escape)
+			require.NoError(t, jenc.Encode(v.base))
+
+			var out bytes.Buffer
+			enc := ffjson.NewEncoder(&out)
+			enc.SetEscapeHTML(escape)
+			require.NoError(t, enc.EncodeFast(v.ff))
+			require.Equal(t, strings.TrimSuffix(expect.String(), "\n"), out.String(), "%T escape=%v", v.ff, escape)
+
+			out.Reset()
+			enc.SetIndent("", " ")
+			require.NoError(t, enc.EncodeFast(v.ff))
+			jenc.SetIndent("", " ")
+			expect.Reset()
+			require.NoError(t, jenc.Encode(v.base))
+			require.Equal(t, strings.TrimSuffix(expect.String(), "\n"), out.String(), "%T escape=%v", v.ff, escape)
+		}
+	}
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestEncoderArray(t *testing.T) {
+	var w countingWriter
+	enc := ffjson.NewEncoder(&w)
+	require.NoError(t, enc.BeginArray())
+	const n = 5000
+	expect := make([]interface{}, 0, n+1)
+	for i := 0; i < n; i++ {
+		r := newLogFFRecord()
+		r.Timestamp = int64(i)
+		require.NoError(t, enc.Encode(r))
+		expect = append(expect, r)
+	}
+	// Types without generated code are written through the buffer too.
+	require.NoError(t, enc.Encode(map[string]int{"a": 1}))
+	expect = append(expect, map[string]int{"a": 1})
+	require.True(t, w.writes > 1, "output should be flushed while writing")
+	require.True(t, w.writes < n/10, "output should be written in chunks")
+	require.NoError(t, enc.End())
+
+	want, err := json.Marshal(expect)
+	require.NoError(t, err)
+	require.Equal(t, string(want), w.String())
+}
+
+func TestEncoderObject(t *testing.T) {
+	x := &Xstring{X: "x"}
+	var w bytes.Buffer
+	enc := ffjson.NewEncoder(&w)
+	enc.SetIndent("", " ")
+	require.NoError(t, enc.BeginObject())
+	require.NoError(t, enc.Key("a"))
+	require.NoError(t, enc.Encode(x))
+	require.NoError(t, enc.Key("b"))
+	require.NoError(t, enc.BeginArray())
+	require.NoError(t, enc.Encode(x))
+	require.NoError(t, enc.Encode(1))
+	require.NoError(t, enc.End())
+	require.NoError(t, enc.Key("c"))
+	require.NoError(t, enc.BeginArray())
+	require.NoError(t, enc.End())
+	require.NoError(t, enc.End())
+
+	want, err := json.MarshalIndent(map[string]interface{}{
+		"a": x,
+		"b": []interface{}{x, 1},
+		"c": []int{},
+	}, "", " ")
+	require.NoError(t, err)
+	require.Equal(t, string(want), w.String())
+
+	// Encode resumes writing whole values once the object is closed.
+	w.Reset()
+	require.NoError(t, enc.Encode(x))
+	want, err = json.MarshalIndent(x, "", " ")
+	require.NoError(t, err)
+	require.Equal(t, string(want), w.String())
+
+	enc = ffjson.NewEncoder(&w)
+	require.Error(t, enc.End())
+	enc = ffjson.NewEncoder(&w)
+	require.NoError(t, enc.BeginObject())
+	err = enc.Encode(x)
+	require.Error(t, err)
+	require.Equal(t, err, enc.End(), "errors should be sticky")
+}
+
+func TestArrayIterator(t *testing.T) {
+	var input bytes.Buffer
+	input.WriteString(" [")
+	const n = 1000
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			input.WriteString(",\n")
+		}
+		fmt.Fprintf(&input, `{"id": %d, "meth": "GET"}`, i)
+	}
+	input.WriteString(", null] ")
+
+	it := ffjson.NewArrayIterator(iotest.HalfReader(&input))
+	defer it.Release()
+	count := 0
+	r := newLogFFRecord()
+	for it.Next(r) {
+		if count < n {
+			require.Equal(t, int64(count), r.Timestamp)
+			require.Equal(t, "GET", r.Method)
+		}
+		count++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, n+1, count)
+	require.False(t, it.Next(r))
+
+	// Types without generated code use encoding/json for each element.
+	it = ffjson.NewArrayIterator(strings.NewReader(`[{"id": 1}, {"id": 2}]`))
+	var ids []int64
+	var rec Record
+	for it.Next(&rec) {
+		ids = append(ids, rec.Timestamp)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []int64{1, 2}, ids)
+
+	it = ffjson.NewArrayIterator(strings.NewReader(`[]`))
+	require.False(t, it.Next(r))
+	require.NoError(t, it.Err())
+}
+
+func TestArrayIteratorErrors(t *testing.T) {
+	for _, input := range []string{``, `{}`, `[{"id": 1}`, `[{"id": 1},]`, `[{"id": 1} {"id": 2}]`, `[{"id": 1}, 2]`} {
+		it := ffjson.NewArrayIterator(strings.NewReader(input))
+		for it.Next(newLogFFRecord()) {
+		}
+		require.Error(t, it.Err(), "input %q", input)
+	}
+
+	it := ffjson.NewArrayIterator(strings.NewReader(`[{"id": 1}, null, {"id": 1, "meth": {"x": [true, 1.5e999]}}]`))
+	for it.Next(newLogFFRecord()) {
+	}
+	var te *fflib.UnmarshalTypeError
+	require.True(t, errors.As(it.Err(), &te), "got %v", it.Err())
+	require.Equal(t, "[2].meth", te.Path)
+	require.Equal(t, "object", te.Value)
+}
+
+func TestErrorPath(t *testing.T) {
+	var x XInterfaces
+	var te *fflib.UnmarshalTypeError
+	err := ffjson.UnmarshalBytes([]byte(`{"M": {"a": [1, 2, 1.5e999]}}`), &x)
+	require.True(t, errors.As(err, &te), "got %v", err)
+	require.Equal(t, "M.a[2]", te.Path)
+	require.Equal(t, "number 1.5e999", te.Value)
+
+	err = ffjson.UnmarshalBytes([]byte(`{"S": [, 1]}`), &x)
+	var se *fflib.SyntaxError
+	require.True(t, errors.As(err, &se), "got %v", err)
+	require.Equal(t, "S", se.Path)
+	require.Equal(t, fflib.FFTok_init, se.Expected)
+	require.Equal(t, fflib.FFTok_comma, se.Actual)
+}
+
+func TestGenericHelpers(t *testing.T) {
+	records := []FFRecord{*newLogFFRecord(), *newLogFFRecord()}
+	records[1].Method = "<POST>"
+
+	out, err := ffjson.MarshalSlice(records)
+	require.NoError(t, err)
+	expect, err := json.Marshal(records)
+	require.NoError(t, err)
+	require.Equal(t, string(expect), string(out))
+
+	back, err := ffjson.UnmarshalSlice[FFRecord](out)
+	require.NoError(t, err)
+	require.Equal(t, records, back)
+
+	m := map[string]*FFRecord{"b": &records[1], "a": &records[0], "nil": nil}
+	out, err = ffjson.MarshalMap(m)
+	require.NoError(t, err)
+	expect, err = json.Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, string(expect), string(out))
+
+	out, err = ffjson.MarshalSlice([]FFRecord(nil))
+	require.NoError(t, err)
+	require.Equal(t, "null", string(out))
+
+	back, err = ffjson.UnmarshalSlice[FFRecord]([]byte(` null`))
+	require.NoError(t, err)
+	require.Nil(t, back)
+
+	back, err = ffjson.UnmarshalSlice[FFRecord]([]byte(`[]`))
+	require.NoError(t, err)
+	require.Equal(t, []FFRecord{}, back)
+
+	_, err = ffjson.UnmarshalSlice[FFRecord]([]byte(`[{"id": 1},`))
+	require.Error(t, err)
+
+	// Only whitespace may follow the array, as for Unmarshal.
+	back, err = ffjson.UnmarshalSlice[FFRecord]([]byte("[{}] \n"))
+	require.NoError(t, err)
+	require.Len(t, back, 1)
+	for _, input := range []string{`[{}] garbage`, `[{}] []`, `null x`} {
+		var dst []FFRecord
+		require.Error(t, ffjson.UnmarshalBytes([]byte(input), &dst), input)
+		_, err = ffjson.UnmarshalSlice[FFRecord]([]byte(input))
+		require.Error(t, err, input)
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	record := newLogFFRecord()
+	expect, err := json.Marshal(record)
+	require.NoError(t, err)
+
+	dst := make([]byte, 0, 1024)
+	dst = append(dst, "prefix:"...)
+	out, err := ffjson.MarshalAppend(dst, record)
+	require.NoError(t, err)
+	require.Equal(t, "prefix:"+string(expect), string(out))
+	require.True(t, &dst[0] == &out[0], "output should be written into dst")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		out, err = ffjson.MarshalAppend(dst[:0], record)
+	})
+	require.NoError(t, err)
+	require.Equal(t, float64(0), allocs)
+
+	// Output that does not fit in dst is moved to a new slice.
+	small := []byte("x")
+	out, err = ffjson.MarshalAppend(small[:1:1], record)
+	require.NoError(t, err)
+	require.Equal(t, "x"+string(expect), string(out))
+	require.Equal(t, "x", string(small))
+
+	// Types without generated code fall back to encoding/json.
+	out, err = ffjson.MarshalAppend(dst[:0], newLogRecord())
+	require.NoError(t, err)
+	expect, err = json.Marshal(newLogRecord())
+	require.NoError(t, err)
+	require.Equal(t, string(expect), string(out))
+
+	out, err = ffjson.MarshalAppend(dst[:1], &GiveError{})
+	require.Error(t, err)
+	require.Equal(t, dst[:1], out)
+}
+
+type maxWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *maxWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestMarshalTo(t *testing.T) {
+	x := &XSAString{}
+	for i := 0; i < 100000; i++ {
+		x.X = append(x.X, [3]string{fmt.Sprint("value ", i)})
+	}
+	expect, err := json.Marshal(x)
+	require.NoError(t, err)
+
+	var w maxWriter
+	require.NoError(t, ffjson.MarshalTo(&w, x))
+	require.Equal(t, string(expect), w.String())
+	require.True(t, w.max < 2*fflib.DefaultFlushSize, "output should be written in chunks, got %d bytes at once", w.max)
+
+	w = maxWriter{}
+	enc := ffjson.NewEncoder(&w)
+	require.NoError(t, enc.Encode(x))
+	require.Equal(t, string(expect), w.String())
+	require.True(t, w.max < 2*fflib.DefaultFlushSize, "output should be written in chunks, got %d bytes at once", w.max)
+
+	// Types without generated code are encoded by encoding/json.
+	w = maxWriter{}
+	require.NoError(t, ffjson.MarshalTo(&w, newLogRecord()))
+	expect, err = json.Marshal(newLogRecord())
+	require.NoError(t, err)
+	require.Equal(t, string(expect), w.String())
+
+	require.Error(t, ffjson.MarshalTo(&w, &GiveError{}))
+
+	// A value that fails before its first chunk writes nothing,
+	// even when the encoder is flushed afterwards.
+	w = maxWriter{}
+	enc = ffjson.NewEncoder(&w)
+	require.Error(t, enc.Encode(&XFloats{F32: 1, F64: math.NaN()}))
+	require.NoError(t, enc.Flush())
+	require.Equal(t, "", w.String())
+	require.Error(t, ffjson.MarshalTo(&w, &XFloats{F32: 1, F64: math.NaN()}))
+	require.Equal(t, "", w.String())
+	require.NoError(t, enc.Encode(&XFloats{F32: 1}))
+	require.True(t, strings.HasPrefix(w.String(), `{"F32":1,`), w.String())
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestStreamBuffer(t *testing.T) {
+	var w bytes.Buffer
+	buf := fflib.NewStreamBuffer(&w, 4)
+	buf.WriteString("abc")
+	require.NoError(t, buf.Rewind(1))
+	require.Equal(t, 0, w.Len())
+	buf.WriteString("de")
+	require.Equal(t, "abde", w.String())
+	require.Error(t, buf.Rewind(1), "flushed data cannot be rewound")
+	buf.WriteByte('f')
+	require.NoError(t, buf.Flush())
+	require.Equal(t, "abdef", w.String())
+
+	buf = fflib.NewStreamBuffer(failWriter{}, 1)
+	require.Error(t, buf.WriteByte('x'))
+	require.Error(t, buf.Flush(), "errors should be sticky")
+}
+
+func init() {
+	// Foreign values are encoded as [A,B] to tell the codec from encoding/json.
+	ffjson.RegisterCodec(func(buf fflib.EncodingBuffer, v *Foreign) error {
+		buf.WriteByte('[')
+		fflib.FormatBits2(buf, uint64(v.A), 10, v.A < 0)
+		buf.WriteByte(',')
+		fflib.WriteJsonString(buf, v.B)
+		buf.WriteByte(']')
+		return nil
+	}, func(data []byte, v *Foreign) error {
+		var raw [2]json.RawMessage
+		err := json.Unmarshal(data, &raw)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(raw[0], &v.A)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(raw[1], &v.B)
+	})
+}
+
+func TestRegisterCodec(t *testing.T) {
+	x := &XCodec{
+		F: Foreign{1, "x"},
+		P: &Foreign{2, "y"},
+		S: []Foreign{{3, "z"}},
+	}
+	const expect = `{"F":[1,"x"],"P":[2,"y"],"N":null,"S":[[3,"z"]]}`
+
+	out, err := ffjson.Marshal(x)
+	require.NoError(t, err)
+	require.Equal(t, expect, string(out))
+
+	var back XCodec
+	err = ffjson.UnmarshalBytes(out, &back)
+	require.NoError(t, err)
+	require.Equal(t, x, &back)
+
+	out, err = ffjson.Marshal(&Foreign{4, "w"})
+	require.NoError(t, err)
+	require.Equal(t, `[4,"w"]`, string(out))
+
+	var f Foreign
+	require.NoError(t, ffjson.Unmarshal(strings.NewReader(`[5,"v"]`), &f))
+	require.Equal(t, Foreign{5, "v"}, f)
+
+	var w bytes.Buffer
+	enc := ffjson.NewEncoder(&w)
+	require.NoError(t, enc.Encode(Foreign{6, "u"}))
+	require.Equal(t, `[6,"u"]`, w.String())
+
+	d := ffjson.NewStreamDecoder(strings.NewReader(`[7,"t"] [8,"s"]`))
+	require.NoError(t, d.Decode(&f))
+	require.Equal(t, Foreign{7, "t"}, f)
+	fp := &f
+	require.NoError(t, d.Decode(&fp))
+	require.Equal(t, Foreign{8, "s"}, *fp)
+}
+
+func TestDecoderLimits(t *testing.T) {
+	requireLimit := func(err error, limit string) {
+		t.Helper()
+		var le *fflib.LimitError
+		require.True(t, errors.As(err, &le), "expected LimitError, got %v", err)
+		require.Equal(t, limit, le.Limit)
+	}
+
+	d := ffjson.NewDecoder()
+	d.SetLimits(fflib.Limits{MaxDepth: 3, MaxElements: 3, MaxStringLength: 8, MaxInputSize: 64})
+
+	var x XInterfaces
+	require.NoError(t, d.Decode(strings.NewReader(`{"S": [1, [2], "abc"]}`), &x))
+	requireLimit(d.Decode(strings.NewReader(`{"S": [[[[1]]]]}`), &x), "MaxDepth")
+	requireLimit(d.Decode(strings.NewReader(`{"I": {"a": [[[]]]}}`), &x), "MaxDepth")
+	requireLimit(d.Decode(strings.NewReader(`{"S": [1, 2, 3, 4]}`), &x), "MaxElements")
+	requireLimit(d.Decode(strings.NewReader(`{"I": "abcdefghi"}`), &x), "MaxStringLength")
+	requireLimit(d.Decode(strings.NewReader(`{"I": "`+strings.Repeat(" ", 64)+`"}`), &x), "MaxInputSize")
+
+	// Nested generated types share the limits.
+	var s XslicePtrStruct
+	requireLimit(d.Decode(strings.NewReader(`{"X": [{"X": "abcdefghi"}]}`), &s), "MaxStringLength")
+
+	// Without generated code only the input size is bounded.
+	var r Record
+	requireLimit(d.Decode(strings.NewReader(`{"id": 1, "meth": "`+strings.Repeat("a", 64)+`"}`), &r), "MaxInputSize")
+
+	// Nesting is bounded without any limits set, so that deep input
+	// cannot overflow the stack of the recursive decoders.
+	deep := `{"I": ` + strings.Repeat("[", 5000000)
+	requireLimit(ffjson.UnmarshalBytes([]byte(deep), &x), "MaxDepth")
+	var m map[string]interface{}
+	dd := ffjson.NewDecoder()
+	dd.SetDuplicateKeys(fflib.DuplicateError)
+	requireLimit(dd.Decode(strings.NewReader(deep), &m), "MaxDepth")
+
+	it := ffjson.NewArrayIterator(strings.NewReader(`[{"id": 1}, {"id": 2}, {"id": 3}]`))
+	it.SetLimits(fflib.Limits{MaxElements: 2})
+	count := 0
+	for it.Next(newLogFFRecord()) {
+		count++
+	}
+	require.Equal(t, 2, count)
+	requireLimit(it.Err(), "MaxElements")
+}
+
+func TestDecodeSize(t *testing.T) {
+	d := ffjson.NewDecoder()
+	var x XInterfaces
+	require.NoError(t, d.DecodeSize(strings.NewReader(`{"I": 1}`), 8, &x))
+	require.Equal(t, float64(1), x.I)
+
+	// A huge size, such as a forged Content-Length, does not
+	// allocate a buffer of that size.
+	require.NoError(t, d.DecodeSize(strings.NewReader(`{}`), 1<<36, &x))
+}
+
+// growTooLarge needs a buffer larger than can be allocated.
+type growTooLarge struct{ n int }
+
+func (g *growTooLarge) UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error {
+	var b fflib.Buffer
+	b.Grow(g.n)
+	return nil
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	var b fflib.Buffer
+	require.PanicsWithValue(t, fflib.ErrTooLarge, func() { b.Grow(1 << 50) })
+
+	for _, n := range []int{1 << 50, math.MaxInt64 / 2} {
+		g := &growTooLarge{n}
+		require.Equal(t, fflib.ErrTooLarge, ffjson.UnmarshalBytes([]byte(`{}`), g))
+		require.Equal(t, fflib.ErrTooLarge, ffjson.Unmarshal(strings.NewReader(`{}`), g))
+		require.Equal(t, fflib.ErrTooLarge, ffjson.NewDecoder().Decode(strings.NewReader(`{}`), g))
+		require.Equal(t, fflib.ErrTooLarge, ffjson.NewStreamDecoder(strings.NewReader(`{}`)).Decode(g))
+	}
+}
+
+func TestTokenAPI(t *testing.T) {
+	var x XPoints
+	require.NoError(t, ffjson.UnmarshalBytes([]byte(`{"Name": "a", "P": [1, 2.5], "Q": [3,4], "S": [[5, 6], null]}`), &x))
+	require.Equal(t, XPoints{Name: "a", P: Point{1, 2.5}, Q: &Point{3, 4}, S: []Point{{5, 6}, {}}}, x)
+
+	var r Route
+	err := ffjson.Unmarshal(iotest.OneByteReader(strings.NewReader(`{
+		"id": 7,
+		"skip": {"a": [1, {"b": 2}]},
+		"stops": [{"Name": "x", "P": [1, 2]}, {"Name": "y", "S": [[3, 4]]}],
+		"extra": {"k": [true, null]}
+	}`)), &r)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), r.ID)
+	require.Equal(t, []XPoints{{Name: "x", P: Point{1, 2}}, {Name: "y", S: []Point{{3, 4}}}}, r.Stops)
+	require.JSONEq(t, `{"k": [true, null]}`, string(r.Extra))
+
+	// Errors of handwritten and generated decoders carry a position.
+	var se *fflib.SyntaxError
+	err = ffjson.UnmarshalBytes([]byte(`{"P": [1 2]}`), &x)
+	require.True(t, errors.As(err, &se), "expected SyntaxError, got %v", err)
+	require.Equal(t, fflib.FFTok_comma, se.Expected)
+	require.Equal(t, "P", se.Path)
+
+	var te *fflib.UnmarshalTypeError
+	err = ffjson.UnmarshalBytes([]byte(`{"stops": [{"Name": 1}]}`), &r)
+	require.True(t, errors.As(err, &te), "expected UnmarshalTypeError, got %v", err)
+	require.Equal(t, "Name", te.Path)
+}
+
+// Route is decoded by hand, and uses the generated decoder
+// of XPoints for its stops.
+// ffjson: skip
+type Route struct {
+	ID    int64
+	Stops []XPoints
+	Extra []byte
+}
+
+// UnmarshalJSONFFLexer decodes a Route.
+func (r *Route) UnmarshalJSONFFLexer(l *fflib.FFLexer, state fflib.FFParseState) error {
+	l.Start(state)
+	kind, err := l.Next()
+	if err != nil {
+		return err
+	}
+	if kind != fflib.KindObjectStart {
+		return l.TypeErr(l.Token, "tff.Route", nil)
+	}
+	for l.More() {
+		key, err := l.ReadString()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			r.ID, err = l.ReadInt64()
+		case "stops":
+			err = r.readStops(l)
+		case "extra":
+			var b []byte
+			b, err = l.Capture()
+			r.Extra = append(r.Extra[:0], b...)
+		default:
+			err = l.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = l.Next()
+	return err
+}
+
+func (r *Route) readStops(l *fflib.FFLexer) error {
+	kind, err := l.Next()
+	if err != nil {
+		return err
+	}
+	if kind != fflib.KindArrayStart {
+		return l.TypeErr(l.Token, "[]tff.XPoints", nil)
+	}
+	for l.More() {
+		var s XPoints
+		if err := l.Decode(&s); err != nil {
+			return err
+		}
+		r.Stops = append(r.Stops, s)
+	}
+	_, err = l.Next()
+	return err
+}
+
 //func TestSimpleUnmarshal(t *testing.T) {
 //	record := newLogFFRecord()
 //
@@ -855,3 +1683,220 @@ func TestUnmarshalFaster(t *testing.T) {
 //	i := 43
 //	testType(t, &TDominantField{Y: &i}, &XDominantField{Y: &i})
 //}
+
+func TestJSON5(t *testing.T) {
+	input := `// service settings
+{
+	name: 'api',
+	port: 0x1F90,
+	ratio: .5,
+	tags: ['a', "b",],
+	limits: {max: +10, 'min': 1,},
+	extra: {list: [1, 2,]},
+	/* one level down */
+	nested: {name: 'inner', ratio: -Infinity},
+}
+`
+	expected := XConfig{
+		Name:   "api",
+		Port:   8080,
+		Ratio:  0.5,
+		Tags:   []string{"a", "b"},
+		Limits: map[string]int{"max": 10, "min": 1},
+		Extra:  map[string]interface{}{"list": []interface{}{1.0, 2.0}},
+		Nested: &XConfig{Name: "inner", Ratio: math.Inf(-1)},
+	}
+
+	d := ffjson.NewDecoder()
+	d.SetSyntax(fflib.SyntaxJSON5)
+	var got XConfig
+	require.NoError(t, d.DecodeFast(iotest.OneByteReader(strings.NewReader(input)), &got))
+	require.Equal(t, expected, got)
+
+	// Types without generated code are converted to JSON for
+	// encoding/json, which cannot hold Infinity.
+	input = strings.Replace(input, "-Infinity", "-1", 1)
+	var m map[string]interface{}
+	require.NoError(t, d.Decode(strings.NewReader(input), &m))
+	require.Equal(t, "inner", m["nested"].(map[string]interface{})["name"])
+
+	got = XConfig{}
+	require.Error(t, ffjson.UnmarshalBytesFast([]byte(input), &got))
+}
+
+func TestUTF8Policy(t *testing.T) {
+	encode := func(p fflib.UTF8Policy, v interface{}) (string, error) {
+		var out bytes.Buffer
+		enc := ffjson.NewEncoder(&out)
+		enc.SetUTF8Policy(p)
+		err := enc.Encode(v)
+		return out.String(), err
+	}
+
+	// Each generated type is read back by encoding/json into the
+	// matching type without generated code.
+	for _, tc := range []struct{ v, back interface{} }{
+		{&Xstring{X: "a\xe6\x97b"}, &Tstring{}},
+		{&XstringTagged{X: "a\xe6\x97b"}, &TstringTagged{}},
+	} {
+		v := tc.v
+		out, err := encode(fflib.UTF8Replace, v)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(out), tc.back))
+		require.Equal(t, "a\ufffd\ufffdb", reflect.ValueOf(tc.back).Elem().Field(0).String())
+
+		out, err = encode(fflib.UTF8PassThrough, v)
+		require.NoError(t, err)
+		require.Contains(t, out, "a\xe6\x97b")
+
+		_, err = encode(fflib.UTF8Reject, v)
+		var ue *fflib.UTF8Error
+		require.True(t, errors.As(err, &ue), "%v", err)
+		require.Equal(t, int64(1), ue.Offset)
+		require.Equal(t, byte(0xe6), ue.Byte)
+	}
+
+	// Output streamed before the invalid string is all that is
+	// written, without a replacement character.
+	big := &XSAString{}
+	for i := 0; i < 10000; i++ {
+		big.X = append(big.X, [3]string{fmt.Sprint("value ", i)})
+	}
+	big.X[5000][1] = "a\xffb"
+	out, err := encode(fflib.UTF8Reject, big)
+	var ue *fflib.UTF8Error
+	require.True(t, errors.As(err, &ue), "%v", err)
+	require.NotEmpty(t, out)
+	require.NotContains(t, out, `\ufffd`)
+	require.NotContains(t, out, "value 5001")
+
+	input := "{\"X\":\"a\xffb\"}"
+	decode := func(p fflib.UTF8Policy, v interface{}) error {
+		d := ffjson.NewDecoder()
+		d.SetUTF8Policy(p)
+		return d.Decode(iotest.OneByteReader(strings.NewReader(input)), v)
+	}
+
+	var x Xstring
+	require.NoError(t, decode(fflib.UTF8Replace, &x))
+	require.Equal(t, "a\ufffdb", x.X)
+	require.NoError(t, decode(fflib.UTF8PassThrough, &x))
+	require.Equal(t, "a\xffb", x.X)
+
+	// Types without generated code are checked before encoding/json
+	// sees them.
+	for _, v := range []interface{}{&Xstring{}, &Tstring{}} {
+		err := decode(fflib.UTF8Reject, v)
+		var ue *fflib.UTF8Error
+		require.True(t, errors.As(err, &ue), "%v", err)
+		require.Equal(t, int64(7), ue.Offset)
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	input := `{"id": 1, "Name": "a", "Counts": {"x": 1, "x": 2}, "Extra": {"k": 1, "k": 2}, "ID": 2, "Name": "b"}`
+	decode := func(p fflib.DuplicateKeyPolicy, v interface{}) error {
+		d := ffjson.NewDecoder()
+		d.SetDuplicateKeys(p)
+		return d.Decode(strings.NewReader(input), v)
+	}
+
+	// The default matches encoding/json.
+	var expected XDuplicate
+	require.NoError(t, json.Unmarshal([]byte(input), &expected))
+	var got XDuplicate
+	require.NoError(t, decode(fflib.DuplicateLastWins, &got))
+	require.Equal(t, expected, got)
+	require.Equal(t, XDuplicate{ID: 2, Name: "b", Counts: map[string]int{"x": 2}, Extra: map[string]interface{}{"k": 2.0}}, got)
+
+	got = XDuplicate{}
+	require.NoError(t, decode(fflib.DuplicateFirstWins, &got))
+	require.Equal(t, XDuplicate{ID: 1, Name: "a", Counts: map[string]int{"x": 1}, Extra: map[string]interface{}{"k": 1.0}}, got)
+
+	got = XDuplicate{}
+	err := decode(fflib.DuplicateError, &got)
+	var de *fflib.DuplicateKeyError
+	require.True(t, errors.As(err, &de), "%v", err)
+	require.Equal(t, "x", de.Key)
+	require.Equal(t, int64(42), de.Offset)
+
+	// Case-insensitive matches of the same field are duplicates too.
+	d := ffjson.NewDecoder()
+	d.SetDuplicateKeys(fflib.DuplicateError)
+	err = d.Decode(strings.NewReader(`{"id": 1, "ID": 2}`), &got)
+	require.True(t, errors.As(err, &de), "%v", err)
+	require.Equal(t, "id", de.Key)
+
+	// Types without generated code are checked before encoding/json
+	// sees them.
+	var m map[string]interface{}
+	err = decode(fflib.DuplicateError, &m)
+	require.True(t, errors.As(err, &de), "%v", err)
+}
+
+func TestFloatParity(t *testing.T) {
+	for _, f := range []float64{0, math.Copysign(0, -1), 0.1, 123456789, 1e20, 1e21, -1.5e21, 1e-6, 1e-7, 2.5e-9, 5e-324, math.MaxFloat64} {
+		f32 := float32(f)
+		if math.IsInf(float64(f32), 0) {
+			f32 = math.MaxFloat32
+		}
+		p := f
+		x := XFloats{F32: f32, F64: f, Ptr: &p, Slice: []float64{f, -f}, Map: map[string]float64{"k": f}, Quoted: f}
+		expected, err := json.Marshal(TFloats(x))
+		require.NoError(t, err)
+		out, err := ffjson.Marshal(&x)
+		require.NoError(t, err)
+		require.Equal(t, string(expected), string(out))
+
+		var back XFloats
+		require.NoError(t, ffjson.UnmarshalBytes(out, &back))
+		require.Equal(t, x, back)
+	}
+}
+
+func TestNonFinitePolicy(t *testing.T) {
+	encode := func(p fflib.NonFinitePolicy, v interface{}) (string, error) {
+		var out bytes.Buffer
+		enc := ffjson.NewEncoder(&out)
+		enc.SetNonFinitePolicy(p)
+		err := enc.Encode(v)
+		return out.String(), err
+	}
+
+	for _, x := range []XFloats{
+		{F32: float32(math.Inf(1))},
+		{F64: math.NaN()},
+		{Slice: []float64{1, math.Inf(-1)}},
+		{Map: map[string]float64{"k": math.NaN()}},
+		{Quoted: math.Inf(1)},
+	} {
+		_, expected := json.Marshal(TFloats(x))
+		_, err := ffjson.Marshal(&x)
+		var ue *json.UnsupportedValueError
+		require.True(t, errors.As(err, &ue), "%v", err)
+		require.Equal(t, expected.Error(), err.Error())
+
+		_, err = encode(fflib.NonFiniteError, &x)
+		require.True(t, errors.As(err, &ue), "%v", err)
+	}
+
+	inf := math.Inf(-1)
+	x := XFloats{F32: float32(math.Inf(1)), F64: math.NaN(), Ptr: &inf, Slice: []float64{math.NaN()}, Map: map[string]float64{"k": math.Inf(1)}, Quoted: math.NaN()}
+
+	out, err := encode(fflib.NonFiniteNull, &x)
+	require.NoError(t, err)
+	require.Equal(t, `{"F32":null,"F64":null,"Ptr":null,"Slice":[null],"Map":{"k":null},"Quoted":"null"}`, out)
+	var back TFloats
+	require.NoError(t, json.Unmarshal([]byte(out), &back))
+	// The generated decoder reads it back like encoding/json.
+	ffback := XFloats{Quoted: 1, Ptr: &inf}
+	require.NoError(t, ffback.UnmarshalJSON([]byte(out)))
+	require.Equal(t, XFloats{Quoted: 1, Slice: []float64{0}, Map: map[string]float64{"k": 0}}, ffback)
+	back = TFloats{Quoted: 1, Ptr: &inf}
+	require.NoError(t, json.Unmarshal([]byte(out), &back))
+	require.Equal(t, TFloats(ffback), back)
+
+	out, err = encode(fflib.NonFiniteString, &x)
+	require.NoError(t, err)
+	require.Equal(t, `{"F32":"Infinity","F64":"NaN","Ptr":"-Infinity","Slice":["NaN"],"Map":{"k":"Infinity"},"Quoted":"NaN"}`, out)
+}
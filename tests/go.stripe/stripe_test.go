@@ -20,6 +20,8 @@ package goser
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	jsoniter "github.com/json-iterator/go"
@@ -27,6 +29,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/denys-klymenko-sigma/ffjson/ffjson"
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
 	base "github.com/denys-klymenko-sigma/ffjson/tests/go.stripe/base"
 	ff "github.com/denys-klymenko-sigma/ffjson/tests/go.stripe/ff"
 )
@@ -63,6 +66,32 @@ func TestRoundTrip(t *testing.T) {
 	assert.Equal(t, litter.Sdump(*cust), litter.Sdump(customerTripped2))
 }
 
+func TestErrorPath(t *testing.T) {
+	input := `{"id": "cus_1", "cards": {"count": 2, "data": [{"exp_month": 1},
+		{"exp_month": "12"}]}}`
+	var customer ff.Customer
+	err := ffjson.UnmarshalBytes([]byte(input), &customer)
+
+	var te *fflib.UnmarshalTypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected UnmarshalTypeError, got %v", err)
+	}
+	assert.Equal(t, "cards.data[1].exp_month", te.Path)
+	assert.Equal(t, "string", te.Value)
+	assert.Equal(t, "int", te.Type)
+	assert.Equal(t, 2, te.Line)
+
+	input = strings.Replace(input, `"12"`, `12]`, 1)
+	err = ffjson.UnmarshalBytes([]byte(input), &customer)
+	var se *fflib.SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected SyntaxError, got %v", err)
+	}
+	assert.Equal(t, "cards.data[1]", se.Path)
+	assert.Equal(t, fflib.FFTok_right_brace, se.Actual)
+	assert.Equal(t, fflib.FFTok_comma, se.Expected)
+}
+
 func BenchmarkMarshalJSON(b *testing.B) {
 	cust := base.NewCustomer()
 
@@ -19,6 +19,7 @@ package ff
 
 import (
 	"encoding/json"
+	"math/big"
 )
 
 // Number struct
@@ -32,3 +33,38 @@ func NewNumber(e *Number) {
 	e.Int = "1"
 	e.Float = "3.14"
 }
+
+// Amounts struct
+type Amounts struct {
+	Num    json.Number
+	NumPtr *json.Number
+	Nums   []json.Number
+	Int    *big.Int
+	Float  *big.Float `ffjson:"number"`
+	Rat    *big.Rat   `ffjson:"number"`
+	IntVal big.Int
+	Ints   []*big.Int
+	ByName map[string]*big.Rat
+}
+
+// BigStrings struct
+type BigStrings struct {
+	Float    *big.Float
+	Rat      *big.Rat
+	FloatVal big.Float
+	Rats     []*big.Rat
+	ByName   map[string]*big.Float
+}
+
+// Formatted struct
+type Formatted struct {
+	Price    float64  `ffjson:"prec=2"`
+	Rate     float32  `ffjson:"fmt=e,prec=3"`
+	Ratio    *float64 `ffjson:"fmt=g"`
+	ID       uint64   `json:"id" ffjson:"base=16"`
+	Offset   int32    `ffjson:"base=16"`
+	Count    int      `ffjson:"string"`
+	Total    float64  `json:",omitempty" ffjson:"prec=1,string"`
+	Checksum *uint32  `ffjson:"base=2"`
+	Weight   float32  `ffjson:"fmt=f"`
+}
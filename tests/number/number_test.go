@@ -19,7 +19,9 @@ package types
 
 import (
 	"encoding/json"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	ff "github.com/denys-klymenko-sigma/ffjson/tests/number/ff"
@@ -68,3 +70,205 @@ func TestUnmarshalFull(t *testing.T) {
 		t.Fatalf("UnmarshalJSON: %v", err)
 	}
 }
+
+// amountsJSON holds numbers that do not fit in a float64 or int64.
+const amountsJSON = `{"Num":123456789012345678901234567890.123456789,` +
+	`"NumPtr":1e400,"Nums":[1,-0.5],` +
+	`"Int":-98765432109876543210987654321,` +
+	`"Float":3.14159265358979323846264338327950288,` +
+	`"Rat":0.1,"IntVal":18446744073709551616,` +
+	`"Ints":[1,null,2],"ByName":{"a":"25/2"}}`
+
+func TestAmountsRoundTrip(t *testing.T) {
+	var record ff.Amounts
+	err := record.UnmarshalJSON([]byte(amountsJSON))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+
+	if record.Num != "123456789012345678901234567890.123456789" || *record.NumPtr != "1e400" {
+		t.Errorf("json.Number not kept verbatim: %v %v", record.Num, *record.NumPtr)
+	}
+	if record.Int.String() != "-98765432109876543210987654321" || record.IntVal.String() != "18446744073709551616" {
+		t.Errorf("unexpected big.Int: %v %v", record.Int, &record.IntVal)
+	}
+	if record.Float.Text('g', -1) != "3.14159265358979323846264338327950288" {
+		t.Errorf("big.Float lost precision: %v", record.Float.Text('g', -1))
+	}
+	if record.Rat.Cmp(big.NewRat(1, 10)) != 0 || record.ByName["a"].Cmp(big.NewRat(25, 2)) != 0 {
+		t.Errorf("unexpected big.Rat: %v %v", record.Rat, record.ByName["a"])
+	}
+	if record.Ints[1] != nil {
+		t.Errorf("null decoded as %v", record.Ints[1])
+	}
+
+	out, err := record.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	if string(out) != amountsJSON {
+		t.Errorf("Expected: %s\n Got: %s", amountsJSON, out)
+	}
+}
+
+func TestAmountsStrings(t *testing.T) {
+	// encoding/json writes big.Float and big.Rat as strings, and
+	// stores strings holding numbers in json.Number.
+	var record ff.Amounts
+	err := record.UnmarshalJSON([]byte(`{"Num":"12","Float":"1.5","Rat":"1/3"}`))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if record.Num != "12" || record.Float.String() != "1.5" || record.Rat.String() != "1/3" {
+		t.Errorf("unexpected values %v %v %v", record.Num, record.Float, record.Rat)
+	}
+
+	// 1/3 cannot be written as a JSON number without rounding.
+	_, err = record.MarshalJSON()
+	if err == nil || !strings.Contains(err.Error(), "1/3") {
+		t.Errorf("expected error for 1/3, got %v", err)
+	}
+}
+
+// bigStrings has no generated code, so encoding/json marshals it.
+type bigStrings ff.BigStrings
+
+func TestBigStrings(t *testing.T) {
+	pi, _ := new(big.Float).SetPrec(200).SetString("3.1415926535897932384626433832795028841971")
+	record := ff.BigStrings{
+		Float:  pi,
+		Rat:    big.NewRat(1, 3),
+		Rats:   []*big.Rat{big.NewRat(5, 1), nil, big.NewRat(-1, 10)},
+		ByName: map[string]*big.Float{"a": big.NewFloat(1e300)},
+	}
+	record.FloatVal.SetInt64(-7)
+
+	// big.Float and big.Rat are strings by default, as for
+	// encoding/json, so 1/3 is written without error.
+	out, err := record.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	expected, err := json.Marshal((*bigStrings)(&record))
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if string(out) != string(expected) {
+		t.Errorf("Expected: %s\n Got: %s", expected, out)
+	}
+
+	var tripped ff.BigStrings
+	if err := tripped.UnmarshalJSON(out); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if tripped.Float.Text('g', -1) != pi.Text('g', -1) || tripped.Rat.Cmp(record.Rat) != 0 || tripped.Rats[2].Cmp(record.Rats[2]) != 0 {
+		t.Errorf("unexpected values %v %v %v", tripped.Float, tripped.Rat, tripped.Rats)
+	}
+
+	var std bigStrings
+	if err := json.Unmarshal(out, &std); err != nil || std.Rat.Cmp(record.Rat) != 0 {
+		t.Errorf("json.Unmarshal: %v %v", std.Rat, err)
+	}
+}
+
+func TestAmountsInvalid(t *testing.T) {
+	for _, input := range []string{
+		`{"Num":"abc"}`,
+		`{"Num":true}`,
+		`{"Int":1.5}`,
+		`{"Int":"1"}`,
+		`{"IntVal":1e3}`,
+		`{"Float":"x"}`,
+		`{"Rat":[1]}`,
+	} {
+		var record ff.Amounts
+		if err := record.UnmarshalJSON([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+
+	record := ff.Amounts{Num: "1.", Rat: big.NewRat(1, 2)}
+	if _, err := record.MarshalJSON(); err == nil {
+		t.Error("invalid json.Number accepted")
+	}
+}
+
+func TestFormattedRoundTrip(t *testing.T) {
+	ratio := 0.000012
+	checksum := uint32(5)
+	record := ff.Formatted{
+		Price:    19.999,
+		Rate:     1234.5,
+		Ratio:    &ratio,
+		ID:       0xdeadbeef,
+		Offset:   -255,
+		Count:    10,
+		Total:    2.25,
+		Checksum: &checksum,
+		Weight:   1.03226797e+09,
+	}
+	// Weight has the shortest digits that read back as the same
+	// float32, as strconv.FormatFloat picks them.
+	expected := `{"Price":20.00,"Rate":1.234e+03,"Ratio":1.2e-05,"id":"deadbeef","Offset":"-ff","Count":"10","Total":"2.2","Checksum":"101","Weight":1032267970}`
+
+	out, err := record.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Expected: %s\n Got: %s", expected, out)
+	}
+
+	var tripped ff.Formatted
+	err = tripped.UnmarshalJSON(out)
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	record.Price, record.Rate, record.Total = 20, 1234, 2.2
+	if !reflect.DeepEqual(record, tripped) {
+		t.Errorf("Expected: %+v\n Got: %+v", record, tripped)
+	}
+
+	// The output is valid JSON for encoding/json too.
+	var generic map[string]interface{}
+	if err := json.Unmarshal(out, &generic); err != nil {
+		t.Errorf("json.Unmarshal: %v", err)
+	}
+
+	record = ff.Formatted{}
+	out, err = record.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	expected = `{"Price":0.00,"Rate":0.000e+00,"Ratio":null,"id":"0","Offset":"0","Count":"0","Checksum":null,"Weight":0}`
+	if string(out) != expected {
+		t.Errorf("Expected: %s\n Got: %s", expected, out)
+	}
+}
+
+func TestFormattedDecode(t *testing.T) {
+	var record ff.Formatted
+	err := record.UnmarshalJSON([]byte(`{"Price":"1.5","id":"FF","Count":7,"Checksum":"1111"}`))
+	if err == nil {
+		t.Fatal("expected error for a quoted Price without the string option")
+	}
+
+	err = record.UnmarshalJSON([]byte(`{"Price":1.5,"id":"FF","Count":7,"Checksum":"1111"}`))
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if record.Price != 1.5 || record.ID != 255 || record.Count != 7 || *record.Checksum != 15 {
+		t.Errorf("unexpected values %+v", record)
+	}
+
+	for _, input := range []string{
+		`{"id":255}`,
+		`{"id":"xyz"}`,
+		`{"Offset":"80000000"}`,
+		`{"Checksum":"102"}`,
+	} {
+		if err := record.UnmarshalJSON([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/**
+ *  Copyright 2014 Paul Querna
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ */
+
+package tff
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/denys-klymenko-sigma/ffjson/ffjson"
+	fflib "github.com/denys-klymenko-sigma/ffjson/fflib/v1"
+)
+
+// testdata/rfc8259 follows the naming of JSONTestSuite: y_ files
+// must be accepted and n_ files rejected in SyntaxStrict.
+func TestStrictCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/rfc8259/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no test files")
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		input, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var valid bool
+		switch {
+		case strings.HasPrefix(name, "y_"):
+			valid = true
+		case strings.HasPrefix(name, "n_"):
+			valid = false
+		default:
+			continue
+		}
+
+		checks := map[string]func([]byte) error{
+			"Validate":        validateStrict,
+			"DecodeInterface": decodeStrict,
+			"token API":       tokensStrict,
+			"Decoder":         decoderStrict,
+		}
+		for check, fn := range checks {
+			err := fn(input)
+			if valid && err != nil {
+				t.Errorf("%s: %s: unexpected error: %v", name, check, err)
+			}
+			if !valid && err == nil {
+				t.Errorf("%s: %s: accepted invalid input", name, check)
+			}
+		}
+	}
+}
+
+func validateStrict(input []byte) error {
+	return fflib.Validate(input, fflib.SyntaxStrict)
+}
+
+// decodeStrict reads the input one byte at a time, so that every
+// token crosses a buffer boundary.
+func decodeStrict(input []byte) error {
+	l := fflib.NewFFLexer(iotest.OneByteReader(bytes.NewReader(input)))
+	l.Syntax = fflib.SyntaxStrict
+	tok := l.Scan()
+	if tok == fflib.FFTok_error {
+		return l.ScanError()
+	}
+	_, err := l.DecodeInterface(tok)
+	if err != nil {
+		return err
+	}
+	return l.ExpectEOF()
+}
+
+func tokensStrict(input []byte) error {
+	l := fflib.NewFFLexerBytes(input)
+	l.Syntax = fflib.SyntaxStrict
+	depth := 0
+	for {
+		kind, err := l.Next()
+		if err == io.EOF {
+			return l.UnexpectedToken(fflib.FFTok_init, fflib.FFTok_eof)
+		}
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case fflib.KindObjectStart, fflib.KindArrayStart:
+			depth++
+		case fflib.KindObjectEnd, fflib.KindArrayEnd:
+			depth--
+		}
+		if depth == 0 {
+			return l.ExpectEOF()
+		}
+	}
+}
+
+func decoderStrict(input []byte) error {
+	d := ffjson.NewDecoder()
+	d.SetSyntax(fflib.SyntaxStrict)
+	var v interface{}
+	return d.Decode(bytes.NewReader(input), &v)
+}
+
+func TestStrictGenerated(t *testing.T) {
+	valid := []string{
+		`{"X": [1, 2], "Y": {"a": 1, "b": 2}}`,
+		`{"X": [], "Y": {}} `,
+		"{\"X\": [1]}\r\n",
+		`{"X": null}`,
+	}
+	invalid := []string{
+		`{"X": [1 2]}`,
+		`{"X": [1,,2]}`,
+		`{"X": [1,]}`,
+		`{"Y": {"a": 1,}}`,
+		`{"Y": {"a": 1 "b": 2}}`,
+		`{"X": [1],}`,
+		`{"X": [01]}`,
+		`{"X": [1]} {}`,
+		`{"X": [1]} // comment`,
+		`{"Z": "\ud800"}`,
+		"{\"Z\": \"\xff\"}",
+		`{"unknown": [1 2]}`,
+		`{"unknown": {"a" 1}}`,
+	}
+
+	d := ffjson.NewDecoder()
+	d.SetSyntax(fflib.SyntaxStrict)
+	for _, input := range valid {
+		var v XStrict
+		err := d.DecodeFast(strings.NewReader(input), &v)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+		}
+	}
+	for _, input := range invalid {
+		var v XStrict
+		err := d.DecodeFast(strings.NewReader(input), &v)
+		if err == nil {
+			t.Errorf("%s: accepted invalid input", input)
+		}
+	}
+
+}
+
+func TestDefaultSyntaxGenerated(t *testing.T) {
+	// SyntaxDefault keeps accepting what ffjson always has.
+	valid := []string{
+		`{"X": [1, 2], "Y": {"a": 1}}`,
+		"{\"X\":\f[1]}\v",
+		"{\"Z\": \"\xff\"}",
+	}
+	// Malformed objects and arrays are rejected, as in SyntaxStrict.
+	invalid := []string{
+		`{"X": [1 2]}`,
+		`{"X": [1,,2]}`,
+		`{"X": [1,]}`,
+		`{"Y": {"a": 1,}}`,
+		`{"Y": {"a": 1 "b": 2}}`,
+		`{"X": [1],}`,
+		`{"X": [01]}`,
+	}
+
+	d := ffjson.NewDecoder()
+	for _, input := range valid {
+		var v XStrict
+		if err := d.DecodeFast(strings.NewReader(input), &v); err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+		}
+		if err := ffjson.UnmarshalBytesFast([]byte(input), &v); err != nil {
+			t.Errorf("%s: unexpected error from UnmarshalBytes: %v", input, err)
+		}
+	}
+	for _, input := range invalid {
+		var v XStrict
+		if err := d.DecodeFast(strings.NewReader(input), &v); err == nil {
+			t.Errorf("%s: accepted invalid input", input)
+		}
+		if err := ffjson.UnmarshalBytesFast([]byte(input), &v); err == nil {
+			t.Errorf("%s: accepted invalid input in UnmarshalBytes", input)
+		}
+	}
+
+	// Decoder ignores what follows the value; UnmarshalBytes does not.
+	var v XStrict
+	if err := d.DecodeFast(strings.NewReader(`{"X": [1]} {}`), &v); err != nil {
+		t.Errorf("unexpected error for trailing data: %v", err)
+	}
+	if err := ffjson.UnmarshalBytesFast([]byte(`{"X": [1]} {}`), &v); err == nil {
+		t.Errorf("UnmarshalBytes accepted trailing data")
+	}
+}
+
+func TestStrictArrayIterator(t *testing.T) {
+	it := ffjson.NewArrayIterator(strings.NewReader(`[{"X": [1]}, {"X": [2]}] x`))
+	it.SetSyntax(fflib.SyntaxStrict)
+	var v XStrict
+	for it.Next(&v) {
+	}
+	if it.Err() == nil {
+		t.Fatal("trailing data accepted after the array")
+	}
+}